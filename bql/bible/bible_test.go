@@ -0,0 +1,76 @@
+package bible_test
+
+import (
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+)
+
+func TestBooks(t *testing.T) {
+	if len(bible.Books) != 66 {
+		t.Fatalf("expected 66 books but got %d", len(bible.Books))
+	}
+}
+
+func TestID(t *testing.T) {
+	inputs := map[string]int{
+		"Genesis":               1,
+		"gen":                   1,
+		"Ge":                    1,
+		"GN":                    1,
+		"1":                     1,
+		" 40 ":                  40,
+		"Matthew":               40,
+		"mat":                   40,
+		"Mt.":                   40,
+		"1 Corinthians":         46,
+		"1 Cor":                 46,
+		"1Co":                   46,
+		"I Corinthians":         46,
+		"i cor.":                46,
+		"First Corinthians":     46,
+		"2nd Corinthians":       47,
+		"III John":              64,
+		"Song of Solomon":       22,
+		"Song of Songs":         22,
+		"Canticles":             22,
+		"psalm":                 19,
+		"Revelations":           66,
+		"ＪＯＨＮ":                  43,
+		"１ John":                62,
+		"Génesis":               1,
+		"jOHN":                  43,
+		"Song-of-Solomon":       22,
+		"Canticle of Canticles": 22,
+		"Jd":                    65,
+		"Jdg":                   7,
+	}
+
+	for input, expected := range inputs {
+		if id, ok := bible.ID(input); !ok || id != expected {
+			t.Fatalf("%q: expected book %d but got %d, %t", input, expected, id, ok)
+		}
+	}
+
+	for _, input := range []string{"", "0", "67", "-1", "Hezekiah", "Jo", "I", "Corinthians", "4 John", "Jud", "Ph"} {
+		if id, ok := bible.ID(input); ok {
+			t.Fatalf("%q: expected no book but got %d", input, id)
+		}
+	}
+}
+
+func TestResolveCanonicalNames(t *testing.T) {
+	for i, b := range bible.Books {
+		if name, ok := bible.Resolve(strings.ToUpper(b)); !ok || name != b {
+			t.Fatalf("%s: expected to resolve to itself but got %q", b, name)
+		}
+		if name := bible.Name(i + 1); name != b {
+			t.Fatalf("%d: expected %s but got %s", i+1, b, name)
+		}
+	}
+
+	if name := bible.Name(67); name != "" {
+		t.Fatalf("expected no book 67 but got %s", name)
+	}
+}
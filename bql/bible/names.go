@@ -0,0 +1,165 @@
+package bible
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// abbreviations lists the common abbreviations and alternative names of the
+// books, in canonical order. Abbreviations shared by two books, such as Jud
+// for Judges and Jude or Ph for Philippians and Philemon, are left out.
+var abbreviations = [][]string{
+	{"Gen", "Ge", "Gn"},
+	{"Exod", "Exo", "Ex"},
+	{"Lev", "Le", "Lv"},
+	{"Num", "Nu", "Nm", "Nb"},
+	{"Deut", "Deu", "De", "Dt"},
+	{"Josh", "Jos", "Jsh"},
+	{"Judg", "Jdg", "Jg", "Jdgs"},
+	{"Rth", "Ru"},
+	{"1 Sam", "1 Sa", "1 Sm"},
+	{"2 Sam", "2 Sa", "2 Sm"},
+	{"1 Kgs", "1 Kin", "1 Ki"},
+	{"2 Kgs", "2 Kin", "2 Ki"},
+	{"1 Chron", "1 Chr", "1 Ch"},
+	{"2 Chron", "2 Chr", "2 Ch"},
+	{"Ezr"},
+	{"Neh", "Ne"},
+	{"Esth", "Est", "Es"},
+	{"Jb"},
+	{"Psalm", "Ps", "Psa", "Pss", "Psm"},
+	{"Prov", "Pro", "Prv", "Pr"},
+	{"Eccles", "Eccl", "Ecc", "Ec", "Qoh", "Qoheleth"},
+	{"Song", "Song of Songs", "SOS", "So", "Canticles", "Canticle of Canticles", "Cant"},
+	{"Isa", "Is"},
+	{"Jer", "Je", "Jr"},
+	{"Lam", "La"},
+	{"Ezek", "Eze", "Ezk"},
+	{"Dan", "Da", "Dn"},
+	{"Hos", "Ho"},
+	{"Jl"},
+	{"Am"},
+	{"Obad", "Ob"},
+	{"Jon", "Jnh"},
+	{"Mic", "Mc"},
+	{"Nah", "Na"},
+	{"Hab", "Hb"},
+	{"Zeph", "Zep", "Zp"},
+	{"Hag", "Hg"},
+	{"Zech", "Zec", "Zc"},
+	{"Mal", "Ml"},
+	{"Matt", "Mat", "Mt"},
+	{"Mrk", "Mar", "Mk"},
+	{"Luk", "Lk"},
+	{"Jhn", "Joh", "Jn"},
+	{"Act", "Ac"},
+	{"Rom", "Ro", "Rm"},
+	{"1 Cor", "1 Co"},
+	{"2 Cor", "2 Co"},
+	{"Gal", "Ga"},
+	{"Ephes", "Eph"},
+	{"Phil", "Php", "Pp"},
+	{"Col"},
+	{"1 Thess", "1 Thes", "1 Th"},
+	{"2 Thess", "2 Thes", "2 Th"},
+	{"1 Tim", "1 Ti"},
+	{"2 Tim", "2 Ti"},
+	{"Tit"},
+	{"Philem", "Phlm", "Phm"},
+	{"Heb"},
+	{"Jas", "Jm"},
+	{"1 Pet", "1 Pe", "1 Pt"},
+	{"2 Pet", "2 Pe", "2 Pt"},
+	{"1 Jn", "1 Jhn", "1 Joh"},
+	{"2 Jn", "2 Jhn", "2 Joh"},
+	{"3 Jn", "3 Jhn", "3 Joh"},
+	{"Jd"},
+	{"Rev", "Re", "Rv", "Revelations", "Apocalypse", "Apoc"},
+}
+
+// ordinals are the spellings of the numbers of 1 Samuel, 2 Kings, 3 John...
+var ordinals = map[string]string{
+	"i": "1", "ii": "2", "iii": "3",
+	"1st": "1", "2nd": "2", "3rd": "3",
+	"first": "1", "second": "2", "third": "3",
+}
+
+// ids maps the keys of the names and abbreviations of the books to their IDs.
+var ids = map[string]int{}
+
+func init() {
+	if len(abbreviations) != len(Books) {
+		panic("bible: abbreviations do not match books")
+	}
+	for i, b := range Books {
+		for _, name := range append([]string{b}, abbreviations[i]...) {
+			k := key(name)
+			if id, ok := ids[k]; ok && id != i+1 {
+				panic("bible: " + name + " names both " + Books[id-1] + " and " + b)
+			}
+			ids[k] = i + 1
+		}
+	}
+}
+
+// key returns the form of a book name used for lookups: case folded, with
+// compatibility characters such as full width digits replaced and without
+// diacritics, spaces or punctuation, and with a leading ordinal written as a
+// digit, so that "I Corinthians", "1 Cor." and "1cor" share their prefix.
+func key(name string) string {
+	// transformers are not safe for concurrent use, make new ones
+	fold := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC, cases.Fold())
+	s, _, err := transform.String(fold, name)
+	if err != nil {
+		s = strings.ToLower(name)
+	}
+
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(fields) > 1 {
+		if n, ok := ordinals[fields[0]]; ok {
+			fields[0] = n
+		}
+	}
+	return strings.Join(fields, "")
+}
+
+// ID returns the canonical ID of a book: its 1-based position in Books. The
+// book is named by its full name, one of its common abbreviations, e.g. Gen,
+// 1 Cor, I Corinthians or Canticles, or its ID as a decimal number. Names are
+// matched case insensitively, ignoring diacritics, spaces and punctuation.
+func ID(name string) (int, bool) {
+	if n, err := strconv.Atoi(strings.TrimSpace(name)); err == nil {
+		if n < 1 || n > len(Books) {
+			return 0, false
+		}
+		return n, true
+	}
+	id, ok := ids[key(name)]
+	return id, ok
+}
+
+// Name returns the canonical name of the book with the given ID, or "" if
+// there is no such book.
+func Name(id int) string {
+	if id < 1 || id > len(Books) {
+		return ""
+	}
+	return Books[id-1]
+}
+
+// Resolve returns the canonical name of the book named name, see ID.
+func Resolve(name string) (string, bool) {
+	id, ok := ID(name)
+	if !ok {
+		return "", false
+	}
+	return Name(id), true
+}
@@ -0,0 +1,195 @@
+package bible
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A ReferenceError records why a scripture reference could not be parsed.
+type ReferenceError struct {
+	Ref string // the reference
+	Err string // what is wrong with it
+}
+
+func (e *ReferenceError) Error() string {
+	return "bible: invalid reference " + strconv.Quote(e.Ref) + ": " + e.Err
+}
+
+// ParseReference returns the verses of a scripture reference, sorted and
+// without duplicates. A reference is a book, see ID, followed by a list of
+// passages separated by commas or semicolons:
+//
+//	Ps 23                whole chapter
+//	Gen 1-3              chapter range
+//	John 3:16            verse
+//	John 3:16-18         verse range
+//	Mat 5:1-7:29         range across chapters
+//	Rom 3:23, 6:23       list
+//	John 3:16, 18        list, 18 is a verse of chapter 3
+//	Jude 3               verse 3 of a book with a single chapter
+//	Gen 1:1, John 1:1    list across books
+//
+// A book alone references the whole book. Chapters and verses must exist in
+// the King James Bible.
+func ParseReference(ref string) ([]VerseID, error) {
+	var p passages
+	for _, s := range strings.Split(strings.ReplaceAll(ref, ";", ","), ",") {
+		if err := p.parse(strings.TrimSpace(s)); err != "" {
+			return nil, &ReferenceError{Ref: ref, Err: err}
+		}
+	}
+	return p.verses(), nil
+}
+
+// dashes replaces the dashes that can separate the ends of a range with -.
+var dashes = strings.NewReplacer("–", "-", "—", "-")
+
+// passages accumulates the verse ranges of the passages of a reference.
+type passages struct {
+	book    int
+	chapter int  // chapter of the last passage
+	inVerse bool // whether the last passage named verses
+	ranges  [][2]VerseID
+}
+
+// parse adds a passage to the ranges, or returns what is wrong with it. The
+// passage starts with a book unless it continues the passages of the previous
+// one, e.g. 6:23 in Rom 3:23, 6:23.
+func (p *passages) parse(s string) string {
+	// the book is everything up to the last letter, e.g. 1 Cor in 1 Cor 13
+	end := 0
+	for i, r := range s {
+		if unicode.IsLetter(r) {
+			end = i + len(string(r))
+		}
+	}
+	if end > 0 {
+		name := strings.TrimSpace(s[:end])
+		book, ok := ID(name)
+		if !ok {
+			return fmt.Sprintf("unknown book '%s'", name)
+		}
+		*p = passages{book: book, ranges: p.ranges}
+		if s = strings.TrimSpace(strings.TrimLeft(s[end:], ".")); s == "" {
+			last := Chapters(book)
+			p.add(1, 1, last, Verses(book, last))
+			return ""
+		}
+	}
+
+	switch {
+	case p.book == 0:
+		return "missing book"
+	case s == "":
+		return "empty passage"
+	}
+	sides := strings.Split(dashes.Replace(s), "-")
+	if len(sides) > 2 {
+		return fmt.Sprintf("invalid passage '%s'", s)
+	}
+
+	c1, v1, ok := splitPassage(sides[0])
+	if !ok {
+		return fmt.Sprintf("invalid passage '%s'", s)
+	}
+	switch {
+	case v1 != 0:
+	case Chapters(p.book) == 1:
+		// Jude 3 is a verse
+		c1, v1 = 1, c1
+	case p.inVerse:
+		// 18 in John 3:16, 18 is a verse
+		c1, v1 = p.chapter, c1
+	}
+
+	c2, v2 := c1, v1
+	if len(sides) == 2 {
+		var ok bool
+		if c2, v2, ok = splitPassage(sides[1]); !ok {
+			return fmt.Sprintf("invalid passage '%s'", s)
+		}
+		if v2 == 0 && v1 != 0 {
+			// 18 in 3:16-18 is a verse
+			c2, v2 = c1, c2
+		}
+	}
+
+	p.chapter, p.inVerse = c2, v1 != 0
+	if v1 == 0 {
+		v1 = 1
+	}
+	if v2 == 0 {
+		v2 = Verses(p.book, c2)
+	}
+
+	for _, cv := range [][2]int{{c1, v1}, {c2, v2}} {
+		if Verses(p.book, cv[0]) == 0 {
+			return fmt.Sprintf("%s has no chapter %d", Name(p.book), cv[0])
+		}
+		if !NewVerseID(p.book, cv[0], cv[1]).Valid() {
+			return fmt.Sprintf("%s %d has no verse %d", Name(p.book), cv[0], cv[1])
+		}
+	}
+	if NewVerseID(p.book, c2, v2) < NewVerseID(p.book, c1, v1) {
+		return fmt.Sprintf("passage '%s' ends before it starts", s)
+	}
+
+	p.add(c1, v1, c2, v2)
+	return ""
+}
+
+// splitPassage splits one side of a passage, "3" or "3:16", into a chapter
+// and a verse, 0 if absent.
+func splitPassage(s string) (int, int, bool) {
+	c, v, hasVerse := strings.Cut(strings.TrimSpace(s), ":")
+	chapter, ok := number(strings.TrimSpace(c))
+	if !ok {
+		return 0, 0, false
+	}
+	if !hasVerse {
+		return chapter, 0, true
+	}
+	verse, ok := number(strings.TrimSpace(v))
+	return chapter, verse, ok && verse != 0
+}
+
+// number parses a positive decimal number.
+func number(s string) (int, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n > 0
+}
+
+func (p *passages) add(c1, v1, c2, v2 int) {
+	p.ranges = append(p.ranges, [2]VerseID{NewVerseID(p.book, c1, v1), NewVerseID(p.book, c2, v2)})
+}
+
+// verses returns the verses of the ranges, sorted and without duplicates.
+func (p *passages) verses() []VerseID {
+	seen := map[VerseID]bool{}
+	var vs []VerseID
+	for _, r := range p.ranges {
+		for c := r[0].Chapter(); c <= r[1].Chapter(); c++ {
+			first, last := 1, Verses(r[0].Book(), c)
+			if c == r[0].Chapter() {
+				first = r[0].Verse()
+			}
+			if c == r[1].Chapter() {
+				last = r[1].Verse()
+			}
+			for v := first; v <= last; v++ {
+				if id := NewVerseID(r[0].Book(), c, v); !seen[id] {
+					seen[id] = true
+					vs = append(vs, id)
+				}
+			}
+		}
+	}
+	sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+	return vs
+}
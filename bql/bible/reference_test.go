@@ -0,0 +1,128 @@
+package bible_test
+
+import (
+	"errors"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+)
+
+func TestVerseCounts(t *testing.T) {
+	chapters, verses := 0, 0
+	for b := range bible.Books {
+		for c := 1; c <= bible.Chapters(b+1); c++ {
+			chapters++
+			verses += bible.Verses(b+1, c)
+		}
+	}
+	if chapters != 1189 || verses != 31102 {
+		t.Fatalf("expected 1189 chapters and 31102 verses but got %d and %d", chapters, verses)
+	}
+
+	if n := bible.Verses(19, 119); n != 176 {
+		t.Fatalf("expected 176 verses in Psalms 119 but got %d", n)
+	}
+	if bible.Chapters(0) != 0 || bible.Chapters(67) != 0 || bible.Verses(43, 22) != 0 {
+		t.Fatalf("expected no such chapters")
+	}
+}
+
+func TestVerseID(t *testing.T) {
+	v := bible.NewVerseID(43, 3, 16)
+	if v != 43003016 || v.Book() != 43 || v.Chapter() != 3 || v.Verse() != 16 {
+		t.Fatalf("unexpected verse ID %d", v)
+	}
+	if s := v.String(); s != "John 3:16" {
+		t.Fatalf("expected John 3:16 but got %s", s)
+	}
+	if !v.Valid() || bible.NewVerseID(43, 3, 37).Valid() || bible.NewVerseID(43, 3, 0).Valid() {
+		t.Fatalf("unexpected validity")
+	}
+}
+
+// verses returns the IDs of the verses of a book from chapter c1, verse v1 to
+// chapter c2, verse v2.
+func verses(book, c1, v1, c2, v2 int) []bible.VerseID {
+	var vs []bible.VerseID
+	for c := c1; c <= c2; c++ {
+		for v := 1; v <= bible.Verses(book, c); v++ {
+			if id := bible.NewVerseID(book, c, v); id >= bible.NewVerseID(book, c1, v1) && id <= bible.NewVerseID(book, c2, v2) {
+				vs = append(vs, id)
+			}
+		}
+	}
+	return vs
+}
+
+func TestParseReference(t *testing.T) {
+	inputs := map[string][]bible.VerseID{
+		"John 3:16":              {43003016},
+		"jn 3:16-18":             {43003016, 43003017, 43003018},
+		"John 3:16, 18":          {43003016, 43003018},
+		"Rom 3:23, 6:23":         {45003023, 45006023},
+		"Rom 6:23; 3:23":         {45003023, 45006023},
+		"John 3:16-17, 3:16":     {43003016, 43003017},
+		"Ps 23":                  verses(19, 23, 1, 23, 6),
+		"Gen 1:1-5":              verses(1, 1, 1, 1, 5),
+		"Gen. 1-2":               verses(1, 1, 1, 2, 25),
+		"1 Cor 13":               verses(46, 13, 1, 13, 13),
+		"I Corinthians 13":       verses(46, 13, 1, 13, 13),
+		"Mat 5:1-7:29":           verses(40, 5, 1, 7, 29),
+		"Mat 5:1 – 7:29":         verses(40, 5, 1, 7, 29),
+		"Mat 27-28:3":            verses(40, 27, 1, 28, 3),
+		"Jude 3":                 {65001003},
+		"Jude 1:3-4":             {65001003, 65001004},
+		"3 John":                 verses(64, 1, 1, 1, 14),
+		"Obadiah":                verses(31, 1, 1, 1, 21),
+		"Gen 1:1, John 1:1":      {1001001, 43001001},
+		"John 1:1; Gen 1:1-2, 5": {1001001, 1001002, 1001005, 43001001},
+		"Jude 2, 3 John 1":       {64001001, 65001002},
+	}
+
+	for input, expected := range inputs {
+		vs, err := bible.ParseReference(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if len(vs) != len(expected) {
+			t.Fatalf("%q: expected %v but got %v", input, expected, vs)
+		}
+		for i := range vs {
+			if vs[i] != expected[i] {
+				t.Fatalf("%q: expected %v but got %v", input, expected, vs)
+			}
+		}
+	}
+}
+
+func TestParseReferenceErrors(t *testing.T) {
+	inputs := map[string]string{
+		"":                "missing book",
+		"3:16":            "missing book",
+		"Hezekiah 1:1":    "unknown book 'Hezekiah'",
+		"John 22":         "John has no chapter 22",
+		"John 3:37":       "John 3 has no verse 37",
+		"John 3:16-40":    "John 3 has no verse 40",
+		"John 3:18-16":    "passage '3:18-16' ends before it starts",
+		"John 4-3":        "passage '4-3' ends before it starts",
+		"John 3:16,":      "empty passage",
+		"John 3:16-17-18": "invalid passage '3:16-17-18'",
+		"John 3:0":        "invalid passage '3:0'",
+		"John 3:x":        "unknown book 'John 3:x'",
+		"John 3:16, x 4":  "unknown book 'x'",
+		"3:16, John 3":    "missing book",
+		"John 3:1 6":      "invalid passage '3:1 6'",
+		"Jude 26":         "Jude 1 has no verse 26",
+	}
+
+	for input, msg := range inputs {
+		_, err := bible.ParseReference(input)
+		var e *bible.ReferenceError
+		if !errors.As(err, &e) {
+			t.Fatalf("%q: expected a *bible.ReferenceError but got %v", input, err)
+		}
+		if e.Ref != input || e.Err != msg {
+			t.Fatalf("%q: expected error %q but got %q", input, msg, e.Err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package bible
+
+import "fmt"
+
+// VerseID identifies a verse as book ID * 1000000 + chapter * 1000 + verse,
+// e.g. 43003016 for John 3:16. Verse IDs sort in canonical order.
+type VerseID int
+
+// NewVerseID returns the ID of a verse. It does not check that the verse
+// exists, see Valid.
+func NewVerseID(book, chapter, verse int) VerseID {
+	return VerseID(book*1000000 + chapter*1000 + verse)
+}
+
+// Book returns the ID of the book of the verse.
+func (v VerseID) Book() int { return int(v) / 1000000 }
+
+// Chapter returns the chapter of the verse.
+func (v VerseID) Chapter() int { return int(v) / 1000 % 1000 }
+
+// Verse returns the number of the verse in its chapter.
+func (v VerseID) Verse() int { return int(v) % 1000 }
+
+// Valid reports whether the verse exists in the King James Bible.
+func (v VerseID) Valid() bool {
+	return v.Verse() >= 1 && v.Verse() <= Verses(v.Book(), v.Chapter())
+}
+
+// String returns the reference of the verse, e.g. John 3:16.
+func (v VerseID) String() string {
+	if name := Name(v.Book()); name != "" {
+		return fmt.Sprintf("%s %d:%d", name, v.Chapter(), v.Verse())
+	}
+	return fmt.Sprintf("VerseID(%d)", int(v))
+}
+
+// Chapters returns the number of chapters of the book with the given ID, or
+// 0 if there is no such book.
+func Chapters(book int) int {
+	if book < 1 || book > len(verseCounts) {
+		return 0
+	}
+	return len(verseCounts[book-1])
+}
+
+// Verses returns the number of verses of a chapter, or 0 if there is no such
+// chapter.
+func Verses(book, chapter int) int {
+	if chapter < 1 || chapter > Chapters(book) {
+		return 0
+	}
+	return verseCounts[book-1][chapter-1]
+}
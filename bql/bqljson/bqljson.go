@@ -0,0 +1,272 @@
+// Package bqljson encodes parsed BQL queries as JSON and decodes them back.
+//
+// A query is wrapped in a Document that records the version of the encoding
+// and, optionally, the text of the query so that the spans of the nodes can
+// still be used to point at the source after the query crossed a process
+// boundary:
+//
+//	{
+//	  "version": 1,
+//	  "source": "book = john",
+//	  "query": {
+//	    "kind": "query", "span": {"start": 0, "end": 11},
+//	    "clause": {
+//	      "kind": "comparison", "span": {"start": 0, "end": 11},
+//	      "field": {"kind": "field", "span": {"start": 0, "end": 4}, "name": "book"},
+//	      "operator": "EQ",
+//	      "operand": {"kind": "string", "span": {"start": 7, "end": 11}, "value": "john"}
+//	    }
+//	  }
+//	}
+//
+// Every node has a kind and a span. The other members depend on the kind:
+//
+//	query       clause, orderBy, limit, offset
+//	or, and     clauses
+//	not         clause
+//	comparison  field, operator, operand
+//	field       name
+//	string      value
+//	ref         value (the reference as written, e.g. "John 3:16-18")
+//	number      value, float (true for floating point literals)
+//	function    name, args
+//	list        values
+//	near        first, second, distance, ordered, acrossVerses
+//	sortKey     field, descending
+//
+// Operators are encoded with the names of their state.ElementType. Members
+// with a zero value are omitted.
+package bqljson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// Version is the version of the encoding. It is bumped whenever the encoding
+// changes in a way older decoders cannot handle. Documents of older versions
+// are still decoded.
+//
+// Version 2 added ref nodes, version 3 near nodes and the NEAR operator.
+const Version = 3
+
+// Document is the JSON envelope of a query. Source is the query text the spans
+// of the query refer to; it may be empty.
+type Document struct {
+	Source string
+	Query  *parser.Query
+}
+
+// Marshal returns the JSON encoding of q, without its source.
+func Marshal(q *parser.Query) ([]byte, error) {
+	return json.Marshal(Document{Query: q})
+}
+
+// Unmarshal decodes a query encoded by Marshal or as a Document.
+func Unmarshal(data []byte) (*parser.Query, error) {
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, err
+	}
+	return d.Query, nil
+}
+
+type document struct {
+	Version int       `json:"version"`
+	Source  string    `json:"source,omitempty"`
+	Query   *jsonNode `json:"query"`
+}
+
+type jsonSpan struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// jsonNode is the union of all the node encodings.
+type jsonNode struct {
+	Kind       string            `json:"kind"`
+	Span       jsonSpan          `json:"span"`
+	Name       string            `json:"name,omitempty"`
+	Value      json.RawMessage   `json:"value,omitempty"`
+	Float      bool              `json:"float,omitempty"`
+	Field      *jsonNode         `json:"field,omitempty"`
+	Operator   state.ElementType `json:"operator,omitempty"`
+	Operand    *jsonNode         `json:"operand,omitempty"`
+	Clause     *jsonNode         `json:"clause,omitempty"`
+	Clauses    []*jsonNode       `json:"clauses,omitempty"`
+	Args       []*jsonNode       `json:"args,omitempty"`
+	Values     []*jsonNode       `json:"values,omitempty"`
+	Descending bool              `json:"descending,omitempty"`
+	OrderBy    []*jsonNode       `json:"orderBy,omitempty"`
+	Limit      *jsonNode         `json:"limit,omitempty"`
+	Offset     *jsonNode         `json:"offset,omitempty"`
+
+	First        *jsonNode `json:"first,omitempty"`
+	Second       *jsonNode `json:"second,omitempty"`
+	Distance     *jsonNode `json:"distance,omitempty"`
+	Ordered      bool      `json:"ordered,omitempty"`
+	AcrossVerses bool      `json:"acrossVerses,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Document) MarshalJSON() ([]byte, error) {
+	if d.Query == nil {
+		return nil, fmt.Errorf("bqljson: no query to encode")
+	}
+	q, err := encode(d.Query)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(document{Version: Version, Source: d.Source, Query: q})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Document) UnmarshalJSON(data []byte) error {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if doc.Version < 1 || doc.Version > Version {
+		return fmt.Errorf("bqljson: unsupported version %d", doc.Version)
+	}
+	if doc.Query == nil {
+		return fmt.Errorf("bqljson: missing query")
+	}
+
+	n, err := decode(doc.Query)
+	if err != nil {
+		return err
+	}
+	q, ok := n.(*parser.Query)
+	if !ok {
+		return fmt.Errorf("bqljson: expected query but got %s", doc.Query.Kind)
+	}
+
+	d.Source, d.Query = doc.Source, q
+	return nil
+}
+
+func encode(n parser.Node) (*jsonNode, error) {
+	if n == nil {
+		return nil, fmt.Errorf("bqljson: missing node")
+	}
+
+	j := &jsonNode{Span: jsonSpan{n.Pos(), n.End()}}
+	var err error
+	switch n := n.(type) {
+	case *parser.Query:
+		j.Kind = "query"
+		if j.Clause, err = encode(n.Clause); err != nil {
+			return nil, err
+		}
+		for _, k := range n.OrderBy {
+			jk, err := encode(k)
+			if err != nil {
+				return nil, err
+			}
+			j.OrderBy = append(j.OrderBy, jk)
+		}
+		if n.Limit != nil {
+			j.Limit, _ = encode(n.Limit)
+		}
+		if n.Offset != nil {
+			j.Offset, _ = encode(n.Offset)
+		}
+	case *parser.SortKey:
+		j.Kind = "sortKey"
+		j.Field, err = encodeField(n.Field)
+		j.Descending = n.Descending
+	case *parser.OrClause:
+		j.Kind = "or"
+		j.Clauses, err = encodeClauses(n.Clauses)
+	case *parser.AndClause:
+		j.Kind = "and"
+		j.Clauses, err = encodeClauses(n.Clauses)
+	case *parser.NotClause:
+		j.Kind = "not"
+		j.Clause, err = encode(n.Clause)
+	case *parser.Comparison:
+		j.Kind = "comparison"
+		if j.Field, err = encodeField(n.Field); err != nil {
+			return nil, err
+		}
+		j.Operator = n.Operator
+		j.Operand, err = encode(n.Value)
+	case *parser.Field:
+		j.Kind = "field"
+		j.Name = n.Name
+	case *parser.StringLiteral:
+		j.Kind = "string"
+		j.Value, err = json.Marshal(n.Value)
+	case *parser.RefLiteral:
+		j.Kind = "ref"
+		j.Value, err = json.Marshal(n.Value)
+	case *parser.NumberLiteral:
+		j.Kind = "number"
+		if n.IsFloat {
+			j.Float = true
+			j.Value = json.RawMessage(strconv.FormatFloat(n.Float, 'g', -1, 64))
+		} else {
+			j.Value = json.RawMessage(strconv.FormatInt(n.Int, 10))
+		}
+	case *parser.FunctionCall:
+		j.Kind = "function"
+		j.Name = n.Name
+		for _, a := range n.Args {
+			ja, err := encode(a)
+			if err != nil {
+				return nil, err
+			}
+			j.Args = append(j.Args, ja)
+		}
+	case *parser.List:
+		j.Kind = "list"
+		for _, v := range n.Values {
+			jv, err := encode(v)
+			if err != nil {
+				return nil, err
+			}
+			j.Values = append(j.Values, jv)
+		}
+	case *parser.Proximity:
+		j.Kind = "near"
+		if n.First == nil || n.Second == nil || n.Distance == nil {
+			return nil, fmt.Errorf("bqljson: missing node")
+		}
+		j.First, _ = encode(n.First)
+		j.Second, _ = encode(n.Second)
+		j.Distance, _ = encode(n.Distance)
+		j.Ordered = n.Ordered
+		j.AcrossVerses = n.AcrossVerses
+	default:
+		return nil, fmt.Errorf("bqljson: unexpected node type %T", n)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func encodeField(f *parser.Field) (*jsonNode, error) {
+	if f == nil {
+		return nil, fmt.Errorf("bqljson: missing field")
+	}
+	return encode(f)
+}
+
+func encodeClauses(cs []parser.Clause) ([]*jsonNode, error) {
+	js := make([]*jsonNode, len(cs))
+	for i, c := range cs {
+		j, err := encode(c)
+		if err != nil {
+			return nil, err
+		}
+		js[i] = j
+	}
+	return js, nil
+}
@@ -0,0 +1,144 @@
+package bqljson_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/bqljson"
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+// spans lists the spans of the nodes of the tree rooted at n in walk order.
+func spans(n parser.Node) []parser.Span {
+	var ss []parser.Span
+	parser.Inspect(n, func(n parser.Node) bool {
+		if n != nil {
+			ss = append(ss, parser.Span{Start: n.Pos(), Stop: n.End()})
+		}
+		return true
+	})
+	return ss
+}
+
+func TestRoundTrip(t *testing.T) {
+	data, err := os.ReadFile("../parser/testdata/queries.bql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range strings.Split(string(data), "\n") {
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		b, err := json.Marshal(bqljson.Document{Source: input, Query: q})
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		var d bqljson.Document
+		if err := json.Unmarshal(b, &d); err != nil {
+			t.Fatalf("%q: unexpected error decoding %s: %s", input, b, err)
+		}
+
+		if d.Source != input {
+			t.Fatalf("%q: expected source to round-trip but got %q", input, d.Source)
+		}
+		if !parser.Equal(q, d.Query) {
+			t.Fatalf("%q: decoded a different tree from %s", input, b)
+		}
+
+		expected, got := spans(q), spans(d.Query)
+		for i := range expected {
+			if expected[i] != got[i] {
+				t.Fatalf("%q: expected span %v but got %v for node %d", input, expected[i], got[i], i)
+			}
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	q, err := parser.Parse("book = john order by verse desc limit 5")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b, err := bqljson.Marshal(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{"version":3,"query":{"kind":"query","span":{"start":0,"end":39},` +
+		`"clause":{"kind":"comparison","span":{"start":0,"end":11},` +
+		`"field":{"kind":"field","span":{"start":0,"end":4},"name":"book"},"operator":"EQ",` +
+		`"operand":{"kind":"string","span":{"start":7,"end":11},"value":"john"}},` +
+		`"orderBy":[{"kind":"sortKey","span":{"start":21,"end":31},` +
+		`"field":{"kind":"field","span":{"start":21,"end":26},"name":"verse"},"descending":true}],` +
+		`"limit":{"kind":"number","span":{"start":38,"end":39},"value":5}}}`
+	if string(b) != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, b)
+	}
+
+	q2, err := bqljson.Unmarshal(b)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !parser.Equal(q, q2) {
+		t.Fatalf("decoded a different tree")
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	const comparison = `{"kind":"comparison","field":{"kind":"field","name":"book"},"operator":"EQ","operand":{"kind":"string","value":"john"}}`
+
+	inputs := map[string]string{
+		`{"version":4,"query":{"kind":"query","clause":` + comparison + `}}`: "unsupported version 4",
+		`{"version":0,"query":{"kind":"query","clause":` + comparison + `}}`: "unsupported version 0",
+		`{"version":2,"query":{"kind":"query","clause":{"kind":"comparison","field":{"kind":"field","name":"ref"},"operator":"EQ","operand":{"kind":"ref","value":"John 3:99"}}}}`: `invalid reference "John 3:99": John 3 has no verse 99`,
+		`{"version":1}`: "missing query",
+		`{"version":1,"query":` + comparison + `}`:                                                                                                                                   "expected query but got comparison",
+		`{"version":1,"query":{"kind":"query"}}`:                                                                                                                                     "missing clause",
+		`{"version":1,"query":{"kind":"query","clause":{"kind":"string","value":"john"}}}`:                                                                                           "expected clause but got string",
+		`{"version":1,"query":{"kind":"query","clause":{"kind":"xor"}}}`:                                                                                                             `unknown node kind "xor"`,
+		`{"version":1,"query":{"kind":"query","clause":{"kind":"and"}}}`:                                                                                                             "and node without clauses",
+		`{"version":1,"query":{"kind":"query","clause":{"kind":"comparison","operator":"EQUALS"}}}`:                                                                                  `unknown operator "EQUALS"`,
+		`{"version":1,"query":{"kind":"query","clause":{"kind":"comparison","operator":"EQ"}}}`:                                                                                      "missing field",
+		`{"version":1,"query":{"kind":"query","clause":` + comparison + `,"limit":{"kind":"number","value":1.5,"float":true}}}`:                                                      "expected non-negative integer",
+		`{"version":1,"query":{"kind":"query","clause":` + comparison + `,"offset":{"kind":"number","value":"x"}}}`:                                                                  "invalid number value",
+		`{"version":3,"query":{"kind":"query","clause":{"kind":"comparison","field":{"kind":"field","name":"text"},"operator":"NEAR","operand":{"kind":"string","value":"faith"}}}}`: "unexpected string operand of operator NEAR",
+		`{"version":3,"query":{"kind":"query","clause":{"kind":"comparison","field":{"kind":"field","name":"text"},"operator":"EQ","operand":{"kind":"near","first":{"kind":"string","value":"faith"},"second":{"kind":"string","value":"works"},"distance":{"kind":"number","value":5}}}}}`:   "unexpected near operand of operator EQ",
+		`{"version":3,"query":{"kind":"query","clause":{"kind":"comparison","field":{"kind":"field","name":"text"},"operator":"NEAR","operand":{"kind":"near","first":{"kind":"string","value":"faith"},"distance":{"kind":"number","value":5}}}}}`:                                            "expected string",
+		`{"version":3,"query":{"kind":"query","clause":{"kind":"comparison","field":{"kind":"field","name":"text"},"operator":"NEAR","operand":{"kind":"near","first":{"kind":"string","value":"faith"},"second":{"kind":"string","value":"works"},"distance":{"kind":"number","value":0}}}}}`: "expected positive integer but got 0",
+	}
+
+	const book = `{"kind":"field","name":"book"}`
+	const john = `{"kind":"string","value":"john"}`
+	for input, msg := range map[string]string{
+		`{"kind":"function","name":"count"}`:                                                                                                  "count() with 0 arguments",
+		`{"kind":"function","name":"count","args":[` + john + `]}`:                                                                            "expected clause but got string",
+		`{"kind":"function","name":"count","args":[` + comparison + `,` + comparison + `]}`:                                                   "count() with 2 arguments",
+		`{"kind":"function","name":"last"}`:                                                                                                   "unexpected operand function last",
+		`{"kind":"function","name":"nosuch"}`:                                                                                                 "unknown function nosuch",
+		`{"kind":"not","clause":{"kind":"function","name":"count","args":[` + comparison + `]}}`:                                              "unexpected query function count",
+		`{"kind":"comparison","field":` + book + `,"operator":"IN","operand":` + john + `}`:                                                   "unexpected string operand of operator IN",
+		`{"kind":"comparison","field":` + book + `,"operator":"NOT_IN","operand":` + john + `}`:                                               "unexpected string operand of operator NOT_IN",
+		`{"kind":"comparison","field":` + book + `,"operator":"EQ","operand":{"kind":"list","values":[` + john + `]}}`:                        "unexpected list operand of operator EQ",
+		`{"kind":"comparison","field":` + book + `,"operator":"EQ","operand":{"kind":"function","name":"count","args":[` + comparison + `]}}`: "unexpected query function count",
+	} {
+		inputs[`{"version":3,"query":{"kind":"query","clause":`+input+`}}`] = msg
+	}
+
+	for input, msg := range inputs {
+		_, err := bqljson.Unmarshal([]byte(input))
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%s: expected error %q but got %v", input, msg, err)
+		}
+	}
+}
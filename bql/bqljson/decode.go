@@ -0,0 +1,335 @@
+package bqljson
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+var operators = map[state.ElementType]bool{
+	state.IN:     true,
+	state.NOT_IN: true,
+	state.NEAR:   true,
+}
+
+func init() {
+	for op := range state.SIMPLE_OPERATORS {
+		operators[op] = true
+	}
+}
+
+// decode converts j back into a node, checking that every node appears where
+// the parser could have put it.
+func decode(j *jsonNode) (parser.Node, error) {
+	span := parser.Span{Start: j.Span.Start, Stop: j.Span.End}
+	switch j.Kind {
+	case "query":
+		q := &parser.Query{Span: span}
+		var err error
+		if q.Clause, err = decodeClause(j.Clause, parser.QueryFunction, parser.ClauseFunction); err != nil {
+			return nil, err
+		}
+		for _, jk := range j.OrderBy {
+			k, err := decodeSortKey(jk)
+			if err != nil {
+				return nil, err
+			}
+			q.OrderBy = append(q.OrderBy, k)
+		}
+		if j.Limit != nil {
+			if q.Limit, err = decodeCount(j.Limit); err != nil {
+				return nil, err
+			}
+		}
+		if j.Offset != nil {
+			if q.Offset, err = decodeCount(j.Offset); err != nil {
+				return nil, err
+			}
+		}
+		return q, nil
+	case "sortKey":
+		f, err := decodeField(j.Field)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.SortKey{Span: span, Field: f, Descending: j.Descending}, nil
+	case "or", "and":
+		if len(j.Clauses) == 0 {
+			return nil, fmt.Errorf("bqljson: %s node without clauses", j.Kind)
+		}
+		cs := make([]parser.Clause, len(j.Clauses))
+		for i, jc := range j.Clauses {
+			c, err := decodeClause(jc, parser.ClauseFunction)
+			if err != nil {
+				return nil, err
+			}
+			cs[i] = c
+		}
+		if j.Kind == "or" {
+			return &parser.OrClause{Span: span, Clauses: cs}, nil
+		}
+		return &parser.AndClause{Span: span, Clauses: cs}, nil
+	case "not":
+		c, err := decodeClause(j.Clause, parser.ClauseFunction)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.NotClause{Span: span, Clause: c}, nil
+	case "comparison":
+		if !operators[j.Operator] {
+			return nil, fmt.Errorf("bqljson: unknown operator %q", j.Operator)
+		}
+		f, err := decodeField(j.Field)
+		if err != nil {
+			return nil, err
+		}
+		if j.Operand != nil && (j.Operand.Kind == "near") != (j.Operator == state.NEAR) {
+			return nil, fmt.Errorf("bqljson: unexpected %s operand of operator %s", j.Operand.Kind, j.Operator)
+		}
+		var v parser.Operand
+		if j.Operator == state.NEAR {
+			v, err = decodeProximity(j.Operand)
+		} else {
+			v, err = decodeOperand(j.Operand)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list := j.Operator == state.IN || j.Operator == state.NOT_IN
+		if _, ok := v.(*parser.List); ok != list {
+			return nil, fmt.Errorf("bqljson: unexpected %s operand of operator %s", j.Operand.Kind, j.Operator)
+		}
+		return &parser.Comparison{Span: span, Field: f, Operator: j.Operator, Value: v}, nil
+	case "field":
+		if j.Name == "" {
+			return nil, fmt.Errorf("bqljson: field without name")
+		}
+		return &parser.Field{Span: span, Name: j.Name}, nil
+	case "string":
+		s := &parser.StringLiteral{Span: span}
+		if err := json.Unmarshal(j.Value, &s.Value); err != nil {
+			return nil, fmt.Errorf("bqljson: invalid string value: %v", err)
+		}
+		return s, nil
+	case "ref":
+		r := &parser.RefLiteral{Span: span}
+		if err := json.Unmarshal(j.Value, &r.Value); err != nil {
+			return nil, fmt.Errorf("bqljson: invalid ref value: %v", err)
+		}
+		var err error
+		if r.Verses, err = bible.ParseReference(r.Value); err != nil {
+			return nil, fmt.Errorf("bqljson: %v", strings.TrimPrefix(err.Error(), "bible: "))
+		}
+		return r, nil
+	case "number":
+		n := &parser.NumberLiteral{Span: span, IsFloat: j.Float}
+		var err error
+		if n.IsFloat {
+			n.Float, err = strconv.ParseFloat(string(j.Value), 64)
+		} else {
+			n.Int, err = strconv.ParseInt(string(j.Value), 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bqljson: invalid number value %s", j.Value)
+		}
+		return n, nil
+	case "function":
+		if j.Name == "" {
+			return nil, fmt.Errorf("bqljson: function without name")
+		}
+		def, ok := parser.LookupFunction(j.Name)
+		if !ok {
+			return nil, fmt.Errorf("bqljson: unknown function %s", j.Name)
+		}
+		if len(j.Args) < def.MinArgs || len(j.Args) > len(def.Args) {
+			return nil, fmt.Errorf("bqljson: %s() with %d arguments", def.Name, len(j.Args))
+		}
+		f := &parser.FunctionCall{Span: span, Name: def.Name}
+		for i, ja := range j.Args {
+			a, err := decodeArgument(ja, def.Args[i])
+			if err != nil {
+				return nil, err
+			}
+			f.Args = append(f.Args, a)
+		}
+		return f, nil
+	case "near":
+		x := &parser.Proximity{Span: span, Ordered: j.Ordered, AcrossVerses: j.AcrossVerses}
+		var err error
+		if x.First, err = decodeString(j.First); err != nil {
+			return nil, err
+		}
+		if x.Second, err = decodeString(j.Second); err != nil {
+			return nil, err
+		}
+		if j.Distance == nil || j.Distance.Kind != "number" {
+			return nil, fmt.Errorf("bqljson: missing near distance")
+		}
+		n, err := decode(j.Distance)
+		if err != nil {
+			return nil, err
+		}
+		if x.Distance = n.(*parser.NumberLiteral); x.Distance.IsFloat || x.Distance.Int < 1 {
+			return nil, fmt.Errorf("bqljson: expected positive integer but got %s", j.Distance.Value)
+		}
+		return x, nil
+	case "list":
+		l := &parser.List{Span: span}
+		for _, jv := range j.Values {
+			v, err := decodeOperand(jv)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := v.(*parser.List); ok {
+				return nil, fmt.Errorf("bqljson: nested list")
+			}
+			l.Values = append(l.Values, v)
+		}
+		return l, nil
+	}
+	return nil, fmt.Errorf("bqljson: unknown node kind %q", j.Kind)
+}
+
+// decodeClause decodes a clause, in which function calls must be of one of
+// the allowed kinds.
+func decodeClause(j *jsonNode, allowed ...parser.FunctionKind) (parser.Clause, error) {
+	if j == nil {
+		return nil, fmt.Errorf("bqljson: missing clause")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := n.(parser.Clause)
+	if !ok {
+		return nil, fmt.Errorf("bqljson: expected clause but got %s", j.Kind)
+	}
+	if err := checkKind(c, allowed...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodeOperand decodes the operand of a comparison other than near, or a
+// value of a list.
+func decodeOperand(j *jsonNode) (parser.Operand, error) {
+	if j == nil {
+		return nil, fmt.Errorf("bqljson: missing operand")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	o, ok := n.(parser.Operand)
+	if _, near := n.(*parser.Proximity); !ok || near {
+		return nil, fmt.Errorf("bqljson: expected operand but got %s", j.Kind)
+	}
+	if err := checkKind(o, parser.OperandFunction); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func decodeProximity(j *jsonNode) (*parser.Proximity, error) {
+	if j == nil {
+		return nil, fmt.Errorf("bqljson: missing operand")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	return n.(*parser.Proximity), nil
+}
+
+// decodeArgument decodes a function argument of the given kind.
+func decodeArgument(j *jsonNode, k parser.ArgKind) (parser.Node, error) {
+	if k == parser.ArgClause {
+		return decodeClause(j, parser.ClauseFunction)
+	}
+	o, err := decodeOperand(j)
+	if err != nil {
+		return nil, err
+	}
+	var ok bool
+	switch o.(type) {
+	case *parser.StringLiteral:
+		ok = k != parser.ArgNumber
+	case *parser.NumberLiteral:
+		ok = k != parser.ArgString
+	default:
+		ok = k == parser.ArgOperand
+	}
+	if !ok {
+		return nil, fmt.Errorf("bqljson: expected %s argument but got %s", k, j.Kind)
+	}
+	return o, nil
+}
+
+// checkKind reports an error if n is a call to a function of a kind other
+// than the allowed ones.
+func checkKind(n parser.Node, allowed ...parser.FunctionKind) error {
+	f, ok := n.(*parser.FunctionCall)
+	if !ok {
+		return nil
+	}
+	def, _ := parser.LookupFunction(f.Name)
+	for _, k := range allowed {
+		if def.Kind == k {
+			return nil
+		}
+	}
+	return fmt.Errorf("bqljson: unexpected %s function %s", def.Kind, f.Name)
+}
+
+func decodeString(j *jsonNode) (*parser.StringLiteral, error) {
+	if j == nil || j.Kind != "string" {
+		return nil, fmt.Errorf("bqljson: expected string")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	return n.(*parser.StringLiteral), nil
+}
+
+func decodeField(j *jsonNode) (*parser.Field, error) {
+	if j == nil || j.Kind != "field" {
+		return nil, fmt.Errorf("bqljson: missing field")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	return n.(*parser.Field), nil
+}
+
+// decodeCount decodes the value of a limit or offset clause.
+func decodeCount(j *jsonNode) (*parser.NumberLiteral, error) {
+	if j.Kind != "number" {
+		return nil, fmt.Errorf("bqljson: expected number but got %s", j.Kind)
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	if c := n.(*parser.NumberLiteral); !c.IsFloat && c.Int >= 0 {
+		return c, nil
+	}
+	return nil, fmt.Errorf("bqljson: expected non-negative integer but got %s", j.Value)
+}
+
+func decodeSortKey(j *jsonNode) (*parser.SortKey, error) {
+	if j == nil || j.Kind != "sortKey" {
+		return nil, fmt.Errorf("bqljson: expected sort key")
+	}
+	n, err := decode(j)
+	if err != nil {
+		return nil, err
+	}
+	return n.(*parser.SortKey), nil
+}
@@ -0,0 +1,172 @@
+// Bqlfmt formats BQL queries.
+//
+// Usage:
+//
+//	bqlfmt [flags] [path ...]
+//
+// Query files hold one query per line. Every query is rewritten in canonical
+// form, see package format; blank lines and lines starting with # are left
+// as they are. Without an explicit path, bqlfmt formats standard input.
+// Directories are walked for .bql files.
+//
+// The flags are:
+//
+//	-l
+//		Do not print the formatted queries, list the files whose formatting
+//		differs from bqlfmt's instead.
+//	-w
+//		Do not print the formatted queries, overwrite the files with them
+//		instead.
+//
+// Files that hold an invalid query are reported and left untouched.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/format"
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from bqlfmt's")
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: bqlfmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "bqlfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		err := filepath.WalkDir(path, func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (name != path && filepath.Ext(name) != ".bql") {
+				return nil
+			}
+			if err := processFile(name, nil, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 2
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 2
+		}
+	}
+	os.Exit(status)
+}
+
+// processFile formats the queries of the named file, or of in if it is not
+// nil, and prints, lists or writes the result depending on the flags.
+func processFile(filename string, in io.Reader, out io.Writer) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	res, err := formatQueries(filename, src)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(src, res) {
+		if !*list && !*write {
+			_, err = out.Write(res)
+		}
+		return err
+	}
+
+	if *list {
+		fmt.Fprintln(out, filename)
+	}
+	if *write {
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filename, res, fi.Mode().Perm())
+	}
+	if !*list {
+		_, err = out.Write(res)
+	}
+	return err
+}
+
+// formatQueries formats every query of src, one per line. The returned error
+// lists the problems found in every invalid query.
+func formatQueries(filename string, src []byte) ([]byte, error) {
+	var (
+		buf  bytes.Buffer
+		errs []string
+	)
+
+	lines := strings.Split(string(src), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			buf.WriteString(line)
+			continue
+		}
+
+		// parse the line as is so that diagnostic columns match the file
+		q, err := parser.Parse(line)
+		if err != nil {
+			var diags parser.Diagnostics
+			if !errors.As(err, &diags) {
+				return nil, err
+			}
+			for _, d := range diags {
+				errs = append(errs, fmt.Sprintf("%s:%d:%d: %s", filename, i+d.Position.Line, d.Position.Column, d.Message))
+			}
+			continue
+		}
+		buf.WriteString(format.Node(q))
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.New(strings.Join(errs, "\n"))
+	}
+	return buf.Bytes(), nil
+}
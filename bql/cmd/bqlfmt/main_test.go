@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const (
+	input = `# saved queries
+book=john
+
+  text~love AND (book = john)
+`
+	expected = `# saved queries
+book = "john"
+
+text ~ "love" and book = "john"
+`
+)
+
+func TestFormatQueries(t *testing.T) {
+	res, err := formatQueries("saved.bql", []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(res) != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, res)
+	}
+}
+
+func TestFormatQueriesErrors(t *testing.T) {
+	_, err := formatQueries("saved.bql", []byte("book = john\nbook = \n\n  verse >"))
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	msg := "saved.bql:2:8: expected literal, found end of query\n" +
+		"saved.bql:4:10: expected literal, found end of query"
+	if err.Error() != msg {
+		t.Fatalf("expected error\n%s\nbut got\n%s", msg, err)
+	}
+}
+
+func TestProcessFileWrite(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "saved.bql")
+	if err := os.WriteFile(name, []byte(input), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	*write = true
+	defer func() { *write = false }()
+
+	var out bytes.Buffer
+	if err := processFile(name, nil, &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output but got %q", out.String())
+	}
+
+	res, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(res) != expected {
+		t.Fatalf("expected\n%s\nbut got\n%s", expected, res)
+	}
+}
+
+func TestProcessFileList(t *testing.T) {
+	*list = true
+	defer func() { *list = false }()
+
+	var out bytes.Buffer
+	if err := processFile("saved.bql", bytes.NewBufferString(input), &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != "saved.bql\n" {
+		t.Fatalf("expected saved.bql to be listed but got %q", out.String())
+	}
+
+	out.Reset()
+	if err := processFile("saved.bql", bytes.NewBufferString(expected), &out); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output but got %q", out.String())
+	}
+}
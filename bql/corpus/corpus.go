@@ -0,0 +1,254 @@
+// Package corpus holds the text of the King James Bible that queries run
+// against, and loads it from the common distribution formats.
+//
+// Verses are identified by their bible.VerseID, the book, chapter and verse
+// numbers packed into an integer, so that sorting verses by ID puts them in
+// canonical order.
+package corpus
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+)
+
+// Verse is a verse of the corpus.
+type Verse struct {
+	ID   bible.VerseID
+	Text string
+}
+
+// Chapter is a chapter of a book. Verses is in canonical order.
+type Chapter struct {
+	Book   int // book ID
+	Number int
+	Verses []Verse
+}
+
+// Book is a book of the corpus. Chapters is in canonical order.
+type Book struct {
+	ID       int
+	Name     string
+	Chapters []Chapter
+}
+
+// Corpus is a set of verses in canonical order.
+type Corpus struct {
+	verses []Verse
+	books  []Book
+}
+
+// New returns the corpus of the given verses, whatever their order. The
+// verses are checked against the King James Bible: if some of its verses are
+// missing, are given more than once or do not exist, New returns the corpus
+// along with a *ValidationError. The first of duplicate verses is kept;
+// verses that do not exist are left out.
+func New(vs []Verse) (*Corpus, error) {
+	sorted := make([]Verse, len(vs))
+	copy(sorted, vs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+
+	var v ValidationError
+	c := &Corpus{}
+	for _, verse := range sorted {
+		switch n := len(c.verses); {
+		case !verse.ID.Valid():
+			v.Unknown = append(v.Unknown, verse.ID)
+		case n > 0 && c.verses[n-1].ID == verse.ID:
+			if d := len(v.Duplicates); d == 0 || v.Duplicates[d-1] != verse.ID {
+				v.Duplicates = append(v.Duplicates, verse.ID)
+			}
+		default:
+			c.verses = append(c.verses, verse)
+		}
+	}
+	c.group()
+
+	// the verses of the corpus are a sorted subset of the KJV verses
+	i := 0
+	for b := 1; b <= len(bible.Books); b++ {
+		for ch := 1; ch <= bible.Chapters(b); ch++ {
+			for n := 1; n <= bible.Verses(b, ch); n++ {
+				id := bible.NewVerseID(b, ch, n)
+				if i < len(c.verses) && c.verses[i].ID == id {
+					i++
+				} else {
+					v.Missing = append(v.Missing, id)
+				}
+			}
+		}
+	}
+
+	if len(v.Missing) > 0 || len(v.Duplicates) > 0 || len(v.Unknown) > 0 {
+		return c, &v
+	}
+	return c, nil
+}
+
+// group builds the books and chapters of the sorted verses.
+func (c *Corpus) group() {
+	for i, v := range c.verses {
+		b, ch := v.ID.Book(), v.ID.Chapter()
+		if len(c.books) == 0 || c.books[len(c.books)-1].ID != b {
+			c.books = append(c.books, Book{ID: b, Name: bible.Name(b)})
+		}
+		book := &c.books[len(c.books)-1]
+		if len(book.Chapters) == 0 || book.Chapters[len(book.Chapters)-1].Number != ch {
+			book.Chapters = append(book.Chapters, Chapter{Book: b, Number: ch, Verses: c.verses[i:i]})
+		}
+		chapter := &book.Chapters[len(book.Chapters)-1]
+		chapter.Verses = chapter.Verses[:len(chapter.Verses)+1]
+	}
+}
+
+// Len returns the number of verses of the corpus.
+func (c *Corpus) Len() int { return len(c.verses) }
+
+// Verses returns the verses of the corpus in canonical order. The slice must
+// not be modified.
+func (c *Corpus) Verses() []Verse { return c.verses }
+
+// Books returns the books of the corpus in canonical order. The slice must not
+// be modified.
+func (c *Corpus) Books() []Book { return c.books }
+
+// Verse returns the verse with the given ID.
+func (c *Corpus) Verse(id bible.VerseID) (*Verse, bool) {
+	i := sort.Search(len(c.verses), func(i int) bool { return c.verses[i].ID >= id })
+	if i == len(c.verses) || c.verses[i].ID != id {
+		return nil, false
+	}
+	return &c.verses[i], true
+}
+
+// ValidationError lists the differences between a corpus and the verses of
+// the King James Bible. The lists are in canonical order.
+type ValidationError struct {
+	Missing    []bible.VerseID // verses not in the corpus
+	Duplicates []bible.VerseID // verses given more than once
+	Unknown    []bible.VerseID // verses that do not exist in the King James Bible
+}
+
+func (e *ValidationError) Error() string {
+	var ps []string
+	for _, l := range []struct {
+		what string
+		ids  []bible.VerseID
+	}{
+		{"missing", e.Missing},
+		{"duplicate", e.Duplicates},
+		{"unknown", e.Unknown},
+	} {
+		if len(l.ids) == 0 {
+			continue
+		}
+		p := fmt.Sprintf("%d %s verse", len(l.ids), l.what)
+		if len(l.ids) > 1 {
+			p += "s"
+		}
+		var names []string
+		for i, id := range l.ids {
+			if i == 3 {
+				names = append(names, "...")
+				break
+			}
+			names = append(names, id.String())
+		}
+		ps = append(ps, p+" ("+strings.Join(names, ", ")+")")
+	}
+	return "corpus: " + strings.Join(ps, ", ")
+}
+
+// Format is a distribution format of the King James Bible.
+type Format int
+
+const (
+	// JSON is an array of {"book", "chapter", "verse", "text"} objects.
+	// Books are named, see bible.ID, or numbered.
+	JSON Format = iota
+	// CSV is a comma separated book, chapter, verse and text table. The
+	// first row may be a header.
+	CSV
+	// TSV is a tab separated book, chapter, verse and text table. The first
+	// row may be a header.
+	TSV
+	// OSIS is OSIS XML, with either container or milestone verse elements.
+	OSIS
+	// USFM is Unified Standard Format Markers, one or more books per input.
+	USFM
+	// Zefania is Zefania XML.
+	Zefania
+)
+
+func (f Format) String() string {
+	switch f {
+	case JSON:
+		return "json"
+	case CSV:
+		return "csv"
+	case TSV:
+		return "tsv"
+	case OSIS:
+		return "osis"
+	case USFM:
+		return "usfm"
+	case Zefania:
+		return "zefania"
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// Load reads the verses of the inputs, e.g. the files of the books of a USFM
+// distribution, and returns their corpus, see New. Errors reading the inputs
+// are returned without a corpus.
+func Load(f Format, rs ...io.Reader) (*Corpus, error) {
+	var read func(io.Reader) ([]Verse, error)
+	switch f {
+	case JSON:
+		read = readJSON
+	case CSV:
+		read = readCSV
+	case TSV:
+		read = readTSV
+	case OSIS:
+		read = readOSIS
+	case USFM:
+		read = readUSFM
+	case Zefania:
+		read = readZefania
+	default:
+		return nil, fmt.Errorf("corpus: unknown format %s", f)
+	}
+
+	var vs []Verse
+	for _, r := range rs {
+		rvs, err := read(r)
+		if err != nil {
+			return nil, err
+		}
+		vs = append(vs, rvs...)
+	}
+	return New(vs)
+}
+
+// newVerse returns the verse of a book named or numbered by book. It fails if
+// the book is unknown or the numbers are out of the range of verse IDs.
+func newVerse(book string, chapter, verse int, text string) (Verse, error) {
+	b, ok := bible.ID(book)
+	if !ok {
+		return Verse{}, fmt.Errorf("unknown book %q", book)
+	}
+	if chapter < 1 || chapter > 999 || verse < 1 || verse > 999 {
+		return Verse{}, fmt.Errorf("invalid verse %s %d:%d", bible.Name(b), chapter, verse)
+	}
+	return Verse{ID: bible.NewVerseID(b, chapter, verse), Text: normalizeSpace(text)}, nil
+}
+
+// normalizeSpace trims s and replaces its runs of white space, e.g. the line
+// breaks of XML files, with single spaces.
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
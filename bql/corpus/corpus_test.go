@@ -0,0 +1,159 @@
+package corpus_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/corpus"
+)
+
+// sample lists the verses of the testdata/sample.* files.
+var sample = []corpus.Verse{
+	{ID: 1001001, Text: "In the beginning God created the heaven and the earth."},
+	{ID: 1001002, Text: "And the earth was without form, and void; and darkness was upon the face of the deep. And the Spirit of God moved upon the face of the waters."},
+	{ID: 1001003, Text: "And God said, Let there be light: and there was light."},
+	{ID: 43003016, Text: "For God so loved the world, that he gave his only begotten Son, that whosoever believeth in him should not perish, but have everlasting life."},
+	{ID: 65001025, Text: "To the only wise God our Saviour, be glory and majesty, dominion and power, both now and ever. Amen."},
+}
+
+func TestLoad(t *testing.T) {
+	files := map[string]corpus.Format{
+		"sample.json":        corpus.JSON,
+		"sample.csv":         corpus.CSV,
+		"sample.tsv":         corpus.TSV,
+		"sample.osis.xml":    corpus.OSIS,
+		"sample.usfm":        corpus.USFM,
+		"sample.zefania.xml": corpus.Zefania,
+	}
+
+	for name, format := range files {
+		f, err := os.Open("testdata/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := corpus.Load(format, f)
+		f.Close()
+
+		var v *corpus.ValidationError
+		if !errors.As(err, &v) {
+			t.Fatalf("%s: expected a *corpus.ValidationError but got %v", name, err)
+		}
+		if len(v.Missing) != 31102-len(sample) || len(v.Duplicates) != 0 || len(v.Unknown) != 0 {
+			t.Fatalf("%s: unexpected validation error %s", name, err)
+		}
+
+		vs := c.Verses()
+		if len(vs) != len(sample) {
+			t.Fatalf("%s: expected %d verses but got %d", name, len(sample), len(vs))
+		}
+		for i := range vs {
+			if vs[i] != sample[i] {
+				t.Fatalf("%s: expected verse\n%+v\nbut got\n%+v", name, sample[i], vs[i])
+			}
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	inputs := []struct {
+		format corpus.Format
+		input  string
+		msg    string
+	}{
+		{corpus.JSON, `[{"book": "Hezekiah", "chapter": 1, "verse": 1}]`, `corpus: verse 0: unknown book "Hezekiah"`},
+		{corpus.JSON, `[{"book": true}]`, `corpus: verse 0: invalid book true`},
+		{corpus.JSON, `{}`, `corpus: json: cannot unmarshal object into Go value of type []corpus.jsonVerse`},
+		{corpus.CSV, "John,3,16,For God\nJohn,x,17,For God", `corpus: line 2: invalid chapter "x"`},
+		{corpus.CSV, "John,3,16", `corpus: line 1: wrong number of fields`},
+		{corpus.TSV, "John\t3\t16\tFor God\n\nJohn\t3\t1000\tFor God", `corpus: line 3: invalid verse John 3:1000`},
+		{corpus.TSV, "John\t3\t16", `corpus: line 1: expected 4 fields but got 3`},
+		{corpus.OSIS, `<osis><verse osisID="Gen.1">x</verse></osis>`, `corpus: line 1: invalid osisID "Gen.1"`},
+		{corpus.OSIS, `<osis><verse osisID="Gen.1.1">x</osis>`, `corpus: XML syntax error on line 1: element <verse> closed by </osis>`},
+		{corpus.USFM, "\\id GEN\n\\v 1 In the beginning", `corpus: line 2: verse outside of a chapter`},
+		{corpus.USFM, "\\id GEN\n\\c 1\n\\v x In the beginning", `corpus: line 3: invalid \v number "x"`},
+		{corpus.USFM, "\\id XYZ\n\\c 1\n\\v 1 In the beginning", `corpus: line 3: unknown book "XYZ"`},
+		{corpus.Zefania, `<XMLBIBLE><BIBLEBOOK bnumber="1"><CHAPTER cnumber="one">`, `corpus: line 1: invalid cnumber "one"`},
+		{corpus.Zefania, `<XMLBIBLE><BIBLEBOOK bnumber="67"><CHAPTER cnumber="1"><VERS vnumber="1">x</VERS>`, `corpus: line 1: unknown book "67"`},
+		{corpus.Format(10), ``, `corpus: unknown format Format(10)`},
+	}
+
+	for _, input := range inputs {
+		_, err := corpus.Load(input.format, strings.NewReader(input.input))
+		if err == nil || err.Error() != input.msg {
+			t.Fatalf("%s %q: expected error %q but got %v", input.format, input.input, input.msg, err)
+		}
+	}
+}
+
+// all returns a verse for every verse of the King James Bible.
+func all() []corpus.Verse {
+	var vs []corpus.Verse
+	for b := 1; b <= len(bible.Books); b++ {
+		for c := 1; c <= bible.Chapters(b); c++ {
+			for v := 1; v <= bible.Verses(b, c); v++ {
+				vs = append(vs, corpus.Verse{ID: bible.NewVerseID(b, c, v)})
+			}
+		}
+	}
+	return vs
+}
+
+func TestNew(t *testing.T) {
+	vs := all()
+	// reverse the verses, New sorts them
+	for i, j := 0, len(vs)-1; i < j; i, j = i+1, j-1 {
+		vs[i], vs[j] = vs[j], vs[i]
+	}
+
+	c, err := corpus.New(vs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Len() != 31102 || len(c.Books()) != 66 {
+		t.Fatalf("expected 31102 verses in 66 books but got %d in %d", c.Len(), len(c.Books()))
+	}
+
+	ps := c.Books()[18]
+	if ps.ID != 19 || ps.Name != "Psalms" || len(ps.Chapters) != 150 || len(ps.Chapters[118].Verses) != 176 {
+		t.Fatalf("unexpected book %s with %d chapters", ps.Name, len(ps.Chapters))
+	}
+	if ch := ps.Chapters[22]; ch.Book != 19 || ch.Number != 23 || ch.Verses[0].ID != 19023001 {
+		t.Fatalf("unexpected chapter %d of book %d", ch.Number, ch.Book)
+	}
+
+	if v, ok := c.Verse(43003016); !ok || v.ID != 43003016 {
+		t.Fatalf("expected to find John 3:16")
+	}
+	if _, ok := c.Verse(43003037); ok {
+		t.Fatalf("expected not to find John 3:37")
+	}
+}
+
+func TestNewValidation(t *testing.T) {
+	vs := all()
+	vs = append(vs[:5], vs[6:]...)                             // Genesis 1:6
+	vs = append(vs, corpus.Verse{ID: 43003016, Text: "again"}) // John 3:16
+	vs = append(vs, corpus.Verse{ID: 43003016, Text: "and again"})
+	vs = append(vs, corpus.Verse{ID: 43003037}) // John 3:37
+	vs = append(vs, corpus.Verse{ID: 66023001}) // Revelation 23:1
+
+	c, err := corpus.New(vs)
+	var v *corpus.ValidationError
+	if !errors.As(err, &v) {
+		t.Fatalf("expected a *corpus.ValidationError but got %v", err)
+	}
+
+	const msg = "corpus: 1 missing verse (Genesis 1:6), 1 duplicate verse (John 3:16), 2 unknown verses (John 3:37, Revelation 23:1)"
+	if err.Error() != msg {
+		t.Fatalf("expected error %q but got %q", msg, err)
+	}
+	if c.Len() != 31101 {
+		t.Fatalf("expected 31101 verses but got %d", c.Len())
+	}
+	if v, _ := c.Verse(43003016); v.Text != "" {
+		t.Fatalf("expected the first John 3:16 to be kept but got %q", v.Text)
+	}
+}
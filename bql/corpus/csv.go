@@ -0,0 +1,87 @@
+package corpus
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readCSV reads a comma separated table of verses. Texts holding commas or
+// quotes are quoted as described by RFC 4180.
+func readCSV(r io.Reader) ([]Verse, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = 4
+
+	var vs []Verse
+	for {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return vs, nil
+		}
+		if err != nil {
+			var pe *csv.ParseError
+			if errors.As(err, &pe) {
+				return nil, fmt.Errorf("corpus: line %d: %v", pe.Line, pe.Err)
+			}
+			return nil, fmt.Errorf("corpus: %v", err)
+		}
+
+		line, _ := cr.FieldPos(0)
+		if v, ok, err := tableVerse(rec, len(vs) == 0); err != nil {
+			return nil, fmt.Errorf("corpus: line %d: %v", line, err)
+		} else if ok {
+			vs = append(vs, v)
+		}
+	}
+}
+
+// readTSV reads a tab separated table of verses, one per line. Texts are not
+// quoted and cannot hold tabs or line breaks.
+func readTSV(r io.Reader) ([]Verse, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+
+	var vs []Verse
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		rec := strings.Split(strings.TrimSuffix(s.Text(), "\r"), "\t")
+		if len(rec) != 4 {
+			return nil, fmt.Errorf("corpus: line %d: expected 4 fields but got %d", line, len(rec))
+		}
+		if v, ok, err := tableVerse(rec, len(vs) == 0); err != nil {
+			return nil, fmt.Errorf("corpus: line %d: %v", line, err)
+		} else if ok {
+			vs = append(vs, v)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("corpus: %v", err)
+	}
+	return vs, nil
+}
+
+// tableVerse returns the verse of a book, chapter, verse, text record. If
+// first is true, the record may be a header, in which case ok is false.
+func tableVerse(rec []string, first bool) (v Verse, ok bool, err error) {
+	if first && strings.EqualFold(strings.TrimSpace(rec[1]), "chapter") {
+		return Verse{}, false, nil
+	}
+
+	chapter, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+	if err != nil {
+		return Verse{}, false, fmt.Errorf("invalid chapter %q", rec[1])
+	}
+	verse, err := strconv.Atoi(strings.TrimSpace(rec[2]))
+	if err != nil {
+		return Verse{}, false, fmt.Errorf("invalid verse %q", rec[2])
+	}
+
+	v, err = newVerse(strings.TrimSpace(rec[0]), chapter, verse, rec[3])
+	return v, err == nil, err
+}
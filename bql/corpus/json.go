@@ -0,0 +1,42 @@
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+type jsonVerse struct {
+	Book    json.RawMessage `json:"book"`
+	Chapter int             `json:"chapter"`
+	Verse   int             `json:"verse"`
+	Text    string          `json:"text"`
+}
+
+// readJSON reads an array of verses. Books are strings or numbers.
+func readJSON(r io.Reader) ([]Verse, error) {
+	var jvs []jsonVerse
+	if err := json.NewDecoder(r).Decode(&jvs); err != nil {
+		return nil, fmt.Errorf("corpus: %v", err)
+	}
+
+	vs := make([]Verse, len(jvs))
+	for i, jv := range jvs {
+		var book string
+		if err := json.Unmarshal(jv.Book, &book); err != nil {
+			var n int
+			if err := json.Unmarshal(jv.Book, &n); err != nil {
+				return nil, fmt.Errorf("corpus: verse %d: invalid book %s", i, jv.Book)
+			}
+			book = strconv.Itoa(n)
+		}
+
+		v, err := newVerse(book, jv.Chapter, jv.Verse, jv.Text)
+		if err != nil {
+			return nil, fmt.Errorf("corpus: verse %d: %v", i, err)
+		}
+		vs[i] = v
+	}
+	return vs, nil
+}
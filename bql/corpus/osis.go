@@ -0,0 +1,146 @@
+package corpus
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// osisBooks lists the OSIS names of the books in canonical order.
+var osisBooks = []string{
+	"Gen", "Exod", "Lev", "Num", "Deut", "Josh", "Judg", "Ruth", "1Sam", "2Sam",
+	"1Kgs", "2Kgs", "1Chr", "2Chr", "Ezra", "Neh", "Esth", "Job", "Ps", "Prov",
+	"Eccl", "Song", "Isa", "Jer", "Lam", "Ezek", "Dan", "Hos", "Joel", "Amos",
+	"Obad", "Jonah", "Mic", "Nah", "Hab", "Zeph", "Hag", "Zech", "Mal",
+	"Matt", "Mark", "Luke", "John", "Acts", "Rom", "1Cor", "2Cor", "Gal", "Eph",
+	"Phil", "Col", "1Thess", "2Thess", "1Tim", "2Tim", "Titus", "Phlm", "Heb", "Jas",
+	"1Pet", "2Pet", "1John", "2John", "3John", "Jude", "Rev",
+}
+
+// osisSkipped are the elements whose text is not part of the verses.
+var osisSkipped = map[string]bool{"note": true, "title": true}
+
+// readOSIS reads the verses of an OSIS document. Verses are either container
+// elements, <verse osisID="Gen.1.1">...</verse>, or milestones,
+// <verse sID="Gen.1.1" osisID="Gen.1.1"/>...<verse eID="Gen.1.1"/>. Notes and
+// titles are left out.
+func readOSIS(r io.Reader) ([]Verse, error) {
+	d := xml.NewDecoder(r)
+
+	var (
+		vs      []Verse
+		current string // osisID of the verse being read
+		text    strings.Builder
+		depth   int // depth of the verse container element, 0 for milestones
+		skip    int // depth of the skipped element being read
+		level   int
+	)
+	end := func() error {
+		v, err := osisVerse(current, text.String())
+		if err != nil {
+			line, _ := d.InputPos()
+			return fmt.Errorf("corpus: line %d: %v", line, err)
+		}
+		vs = append(vs, v)
+		current, depth = "", 0
+		text.Reset()
+		return nil
+	}
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corpus: %v", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			level++
+			if skip == 0 && osisSkipped[t.Name.Local] {
+				skip = level
+			}
+			if t.Name.Local != "verse" {
+				continue
+			}
+			if eID := attr(t, "eID"); eID != "" {
+				if current != "" {
+					if err := end(); err != nil {
+						return nil, err
+					}
+				}
+				continue
+			}
+			if current != "" {
+				// a new milestone ends the previous one
+				if err := end(); err != nil {
+					return nil, err
+				}
+			}
+			current = attr(t, "osisID")
+			if attr(t, "sID") == "" {
+				depth = level
+			}
+		case xml.EndElement:
+			if depth != 0 && level == depth {
+				if err := end(); err != nil {
+					return nil, err
+				}
+			}
+			if level == skip {
+				skip = 0
+			}
+			level--
+		case xml.CharData:
+			if current != "" && skip == 0 {
+				text.Write(t)
+			}
+		}
+	}
+
+	if current != "" {
+		if err := end(); err != nil {
+			return nil, err
+		}
+	}
+	return vs, nil
+}
+
+// osisVerse returns the verse of an osisID such as Gen.1.1. Of a list of IDs,
+// for verses joined in a single element, the first one is used.
+func osisVerse(osisID, text string) (Verse, error) {
+	id, _, _ := strings.Cut(strings.TrimSpace(osisID), " ")
+	ps := strings.Split(id, ".")
+	if len(ps) != 3 {
+		return Verse{}, fmt.Errorf("invalid osisID %q", osisID)
+	}
+
+	book := ps[0]
+	for i, b := range osisBooks {
+		if b == book {
+			book = strconv.Itoa(i + 1)
+		}
+	}
+	chapter, err := strconv.Atoi(ps[1])
+	if err != nil {
+		return Verse{}, fmt.Errorf("invalid osisID %q", osisID)
+	}
+	verse, err := strconv.Atoi(ps[2])
+	if err != nil {
+		return Verse{}, fmt.Errorf("invalid osisID %q", osisID)
+	}
+	return newVerse(book, chapter, verse, text)
+}
+
+func attr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
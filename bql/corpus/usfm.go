@@ -0,0 +1,160 @@
+package corpus
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// usfmBooks lists the USFM identifiers of the books in canonical order.
+var usfmBooks = []string{
+	"GEN", "EXO", "LEV", "NUM", "DEU", "JOS", "JDG", "RUT", "1SA", "2SA",
+	"1KI", "2KI", "1CH", "2CH", "EZR", "NEH", "EST", "JOB", "PSA", "PRO",
+	"ECC", "SNG", "ISA", "JER", "LAM", "EZK", "DAN", "HOS", "JOL", "AMO",
+	"OBA", "JON", "MIC", "NAM", "HAB", "ZEP", "HAG", "ZEC", "MAL",
+	"MAT", "MRK", "LUK", "JHN", "ACT", "ROM", "1CO", "2CO", "GAL", "EPH",
+	"PHP", "COL", "1TH", "2TH", "1TI", "2TI", "TIT", "PHM", "HEB", "JAS",
+	"1PE", "2PE", "1JN", "2JN", "3JN", "JUD", "REV",
+}
+
+// usfmMarker matches the markers of a USFM file, e.g. \v, \q1, \+w or \w*.
+var usfmMarker = regexp.MustCompile(`\\(\+?[a-z]+[0-9]*)(\*?)`)
+
+// usfmLineMarkers are the markers whose text runs to the end of the line and
+// is not part of the verses: identification, headings and titles.
+var usfmLineMarkers = map[string]bool{
+	"ide": true, "h": true, "toc1": true, "toc2": true, "toc3": true,
+	"mt": true, "mt1": true, "mt2": true, "mt3": true, "ms": true, "ms1": true, "ms2": true,
+	"s": true, "s1": true, "s2": true, "s3": true, "r": true, "d": true, "sp": true,
+	"rem": true, "sts": true, "usfm": true, "cl": true, "cp": true, "mr": true,
+}
+
+// usfmNotes are the markers of footnotes and cross references, which run to
+// their closing marker and are not part of the verses.
+var usfmNotes = map[string]bool{"f": true, "fe": true, "x": true}
+
+// readUSFM reads the verses of one or more USFM books. Footnotes, cross
+// references, headings and the attributes of character markers, e.g. the
+// Strong's numbers of \w In|strong="H7225"\w*, are left out.
+func readUSFM(r io.Reader) ([]Verse, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("corpus: %v", err)
+	}
+	src := string(data)
+
+	var (
+		vs       []Verse
+		book     string
+		chapter  int
+		verse    int
+		text     strings.Builder
+		arg      string // marker waiting for its argument: id, c or v
+		skipLine bool
+		note     string // closing marker of the note being skipped
+	)
+	fail := func(pos int, format string, args ...any) error {
+		line := strings.Count(src[:pos], "\n") + 1
+		return fmt.Errorf("corpus: line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+	flush := func(pos int) error {
+		if verse == 0 {
+			return nil
+		}
+		v, err := newVerse(book, chapter, verse, text.String())
+		if err != nil {
+			return fail(pos, "%v", err)
+		}
+		vs = append(vs, v)
+		verse = 0
+		text.Reset()
+		return nil
+	}
+
+	// handle the text between two markers
+	segment := func(start, stop int) error {
+		s := src[start:stop]
+		if arg != "" {
+			fields := strings.Fields(s)
+			if len(fields) == 0 {
+				return fail(start, "missing argument of \\%s", arg)
+			}
+			s = s[strings.Index(s, fields[0])+len(fields[0]):]
+			switch arg {
+			case "id":
+				book, chapter, skipLine = fields[0], 0, true
+				for i, b := range usfmBooks {
+					if strings.EqualFold(b, book) {
+						book = strconv.Itoa(i + 1)
+					}
+				}
+			case "c", "v":
+				// the first number of a verse range such as 1-2
+				n, err := strconv.Atoi(strings.SplitN(fields[0], "-", 2)[0])
+				if err != nil || n < 1 {
+					return fail(start, "invalid \\%s number %q", arg, fields[0])
+				}
+				if arg == "c" {
+					chapter = n
+				} else if book == "" || chapter == 0 {
+					return fail(start, "verse outside of a chapter")
+				} else {
+					verse = n
+				}
+			}
+			arg = ""
+		}
+
+		if skipLine {
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return nil
+			}
+			s, skipLine = s[i:], false
+		}
+
+		if verse != 0 && note == "" {
+			// the attributes of character markers run to the next marker
+			s, _, _ = strings.Cut(s, "|")
+			text.WriteString(s)
+		}
+		return nil
+	}
+
+	last := 0
+	for _, m := range usfmMarker.FindAllStringSubmatchIndex(src, -1) {
+		if err := segment(last, m[0]); err != nil {
+			return nil, err
+		}
+		last = m[1]
+
+		name := strings.TrimPrefix(src[m[2]:m[3]], "+")
+		closing := m[5] > m[4]
+		switch {
+		case note != "":
+			if closing && name == note {
+				note = ""
+			}
+		case closing:
+			// end of a character marker
+		case usfmNotes[name]:
+			note = name
+		case name == "id", name == "c", name == "v":
+			if err := flush(m[0]); err != nil {
+				return nil, err
+			}
+			arg = name
+		case usfmLineMarkers[name]:
+			skipLine = true
+		}
+	}
+	if err := segment(last, len(src)); err != nil {
+		return nil, err
+	}
+	if err := flush(len(src)); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
@@ -0,0 +1,92 @@
+package corpus
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// readZefania reads the verses of a Zefania XML document:
+//
+//	<XMLBIBLE>
+//	  <BIBLEBOOK bnumber="1" bname="Genesis">
+//	    <CHAPTER cnumber="1">
+//	      <VERS vnumber="1">In the beginning...</VERS>
+//
+// Books are identified by their number, or their name if they have none.
+// Notes are left out.
+func readZefania(r io.Reader) ([]Verse, error) {
+	d := xml.NewDecoder(r)
+
+	var (
+		vs       []Verse
+		book     string
+		chapter  int
+		verse    int
+		text     strings.Builder
+		inVerse  bool
+		noteSkip int // nesting depth of the notes being read
+	)
+	fail := func(format string, args ...any) error {
+		line, _ := d.InputPos()
+		return fmt.Errorf("corpus: line %d: %s", line, fmt.Sprintf(format, args...))
+	}
+	number := func(e xml.StartElement, name string) (int, error) {
+		n, err := strconv.Atoi(strings.TrimSpace(attr(e, name)))
+		if err != nil {
+			return 0, fail("invalid %s %q", name, attr(e, name))
+		}
+		return n, nil
+	}
+
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return vs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("corpus: %v", err)
+		}
+
+		switch t := t.(type) {
+		case xml.StartElement:
+			switch strings.ToUpper(t.Name.Local) {
+			case "BIBLEBOOK":
+				book = attr(t, "bnumber")
+				if book == "" {
+					book = attr(t, "bname")
+				}
+			case "CHAPTER":
+				if chapter, err = number(t, "cnumber"); err != nil {
+					return nil, err
+				}
+			case "VERS":
+				if verse, err = number(t, "vnumber"); err != nil {
+					return nil, err
+				}
+				inVerse = true
+			case "NOTE":
+				noteSkip++
+			}
+		case xml.EndElement:
+			switch strings.ToUpper(t.Name.Local) {
+			case "VERS":
+				v, err := newVerse(book, chapter, verse, text.String())
+				if err != nil {
+					return nil, fail("%v", err)
+				}
+				vs = append(vs, v)
+				inVerse = false
+				text.Reset()
+			case "NOTE":
+				noteSkip--
+			}
+		case xml.CharData:
+			if inVerse && noteSkip == 0 {
+				text.Write(t)
+			}
+		}
+	}
+}
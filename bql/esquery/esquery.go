@@ -0,0 +1,268 @@
+// Package esquery translates BQL queries to the JSON query DSL of
+// Elasticsearch and OpenSearch.
+//
+// Clauses map to bool queries: and to must, or to should, not to must_not.
+// Comparisons map to term, terms, range and match_phrase (for ~, and = on the
+// text field) queries, and near to an intervals query; as documents are
+// verses, near is limited to the verse scope.
+// ORDER BY, LIMIT and OFFSET map to the sort, size and from members of the
+// search request.
+package esquery
+
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// DefaultFields maps the BQL fields to the fields of a verse document.
+var DefaultFields = map[string]string{
+	"book":    "book",
+	"chapter": "chapter",
+	"verse":   "verse",
+	"text":    "text",
+}
+
+var ranges = map[state.ElementType]string{
+	state.LT:  "lt",
+	state.GT:  "gt",
+	state.LTE: "lte",
+	state.GTE: "gte",
+}
+
+// Translator translates queries to the query DSL.
+type Translator struct {
+	// Fields maps BQL fields to document fields. Fields missing from the map
+	// are reported as errors. DefaultFields is used if Fields is nil.
+	Fields map[string]string
+
+	// BookSortField is the numeric document field holding the canonical
+	// index of the book of a verse. Sorting on book fails if it is empty,
+	// since sorting on the book names would not give the canonical order.
+	BookSortField string
+}
+
+// Request is the translation of a query. Body is sent to Endpoint, which is
+// _search, or _count for count(...) queries.
+type Request struct {
+	Endpoint string         `json:"endpoint"`
+	Body     map[string]any `json:"body"`
+}
+
+// Translate translates q with the default fields.
+func Translate(q *parser.Query) (*Request, error) {
+	t := Translator{}
+	return t.Translate(q)
+}
+
+// Translate translates q. It fails on the constructs that have no equivalent
+// in the query DSL, such as the last() function.
+func (t *Translator) Translate(q *parser.Query) (*Request, error) {
+	r := &Request{Endpoint: "_search", Body: map[string]any{}}
+	c, count, err := q.Selection()
+	if err != nil {
+		return nil, err
+	}
+	if count {
+		if len(q.OrderBy) > 0 || q.Limit != nil || q.Offset != nil {
+			return nil, unsupported(q.Clause, "count with order by, limit or offset")
+		}
+		r.Endpoint = "_count"
+	}
+
+	query, err := t.clause(c)
+	if err != nil {
+		return nil, err
+	}
+	r.Body["query"] = query
+
+	if len(q.OrderBy) > 0 {
+		sort := make([]any, len(q.OrderBy))
+		for i, k := range q.OrderBy {
+			f, err := t.field(k.Field)
+			if err != nil {
+				return nil, err
+			}
+			if strings.EqualFold(k.Field.Name, "book") {
+				if t.BookSortField == "" {
+					return nil, unsupported(k, "sorting on book without Translator.BookSortField")
+				}
+				f = t.BookSortField
+			}
+			order := "asc"
+			if k.Descending {
+				order = "desc"
+			}
+			sort[i] = map[string]any{f: map[string]any{"order": order}}
+		}
+		r.Body["sort"] = sort
+	}
+
+	if q.Limit != nil {
+		r.Body["size"] = q.Limit.Int
+	}
+	if q.Offset != nil {
+		r.Body["from"] = q.Offset.Int
+	}
+	return r, nil
+}
+
+func (t *Translator) clause(c parser.Clause) (map[string]any, error) {
+	switch c := c.(type) {
+	case *parser.OrClause:
+		qs, err := t.clauses(c.Clauses)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery(map[string]any{"should": qs, "minimum_should_match": 1}), nil
+	case *parser.AndClause:
+		qs, err := t.clauses(c.Clauses)
+		if err != nil {
+			return nil, err
+		}
+		return boolQuery(map[string]any{"must": qs}), nil
+	case *parser.NotClause:
+		q, err := t.clause(c.Clause)
+		if err != nil {
+			return nil, err
+		}
+		return mustNot(q), nil
+	case *parser.Comparison:
+		return t.comparison(c)
+	case *parser.FunctionCall:
+		return nil, unsupported(c, "function "+c.Name)
+	}
+	return nil, unsupported(c, fmt.Sprintf("%T", c))
+}
+
+func (t *Translator) clauses(cs []parser.Clause) ([]any, error) {
+	qs := make([]any, len(cs))
+	for i, c := range cs {
+		q, err := t.clause(c)
+		if err != nil {
+			return nil, err
+		}
+		qs[i] = q
+	}
+	return qs, nil
+}
+
+func (t *Translator) comparison(c *parser.Comparison) (map[string]any, error) {
+	f, err := t.field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	op := c.Operator
+	if ty, _ := parser.FieldType(c.Field.Name); ty == parser.TextValue {
+		// text = has the phrase semantics of ~
+		switch op {
+		case state.EQ:
+			op = state.CONTAINS
+		case state.NEQ:
+			op = state.NOT_CONTAINS
+		}
+	}
+
+	switch op {
+	case state.IN, state.NOT_IN:
+		l, ok := c.Value.(*parser.List)
+		if !ok {
+			return nil, unsupported(c.Value, "non-list operand of in")
+		}
+		vs := make([]any, len(l.Values))
+		for i, v := range l.Values {
+			if vs[i], err = value(c.Field, v); err != nil {
+				return nil, err
+			}
+		}
+		q := map[string]any{"terms": map[string]any{f: vs}}
+		if c.Operator == state.NOT_IN {
+			return mustNot(q), nil
+		}
+		return q, nil
+	case state.NEAR:
+		x, ok := c.Value.(*parser.Proximity)
+		if !ok {
+			return nil, unsupported(c.Value, "non-proximity operand of near")
+		}
+		return near(f, x)
+	case state.CONTAINS, state.NOT_CONTAINS:
+		s, ok := c.Value.(*parser.StringLiteral)
+		if !ok {
+			return nil, unsupported(c.Value, "non-string operand of ~")
+		}
+		q := map[string]any{"match_phrase": map[string]any{f: s.Value}}
+		if op == state.NOT_CONTAINS {
+			return mustNot(q), nil
+		}
+		return q, nil
+	}
+
+	v, err := value(c.Field, c.Value)
+	if err != nil {
+		return nil, err
+	}
+	switch c.Operator {
+	case state.EQ:
+		return map[string]any{"term": map[string]any{f: v}}, nil
+	case state.NEQ:
+		return mustNot(map[string]any{"term": map[string]any{f: v}}), nil
+	}
+	return map[string]any{"range": map[string]any{f: map[string]any{ranges[c.Operator]: v}}}, nil
+}
+
+// near returns the intervals query matching the phrases of x within its
+// distance: at most distance-1 words apart.
+func near(f string, x *parser.Proximity) (map[string]any, error) {
+	if x.AcrossVerses {
+		return nil, unsupported(x, "near across adjacent verses")
+	}
+	phrase := func(s *parser.StringLiteral) map[string]any {
+		return map[string]any{"match": map[string]any{"query": s.Value, "ordered": true, "max_gaps": 0}}
+	}
+	return map[string]any{"intervals": map[string]any{f: map[string]any{
+		"all_of": map[string]any{
+			"ordered":   x.Ordered,
+			"max_gaps":  x.Distance.Int - 1,
+			"intervals": []any{phrase(x.First), phrase(x.Second)},
+		},
+	}}}, nil
+}
+
+func (t *Translator) field(f *parser.Field) (string, error) {
+	fs := t.Fields
+	if fs == nil {
+		fs = DefaultFields
+	}
+	if name, ok := fs[strings.ToLower(f.Name)]; ok {
+		return name, nil
+	}
+	return "", fmt.Errorf("esquery: %d: no document field for field %s", f.Pos(), f.Name)
+}
+
+func boolQuery(b map[string]any) map[string]any {
+	return map[string]any{"bool": b}
+}
+
+func mustNot(q map[string]any) map[string]any {
+	return boolQuery(map[string]any{"must_not": []any{q}})
+}
+
+// value returns the JSON value of a literal compared to f. Strings are
+// passed in canonical form, e.g. book names as in bible.Books.
+func value(f *parser.Field, o parser.Operand) (any, error) {
+	if v, ok := parser.LiteralValue(f.Name, o); ok {
+		return v, nil
+	}
+	if fc, ok := o.(*parser.FunctionCall); ok {
+		return nil, unsupported(fc, "function "+fc.Name)
+	}
+	return nil, unsupported(o, fmt.Sprintf("%T", o))
+}
+
+func unsupported(n parser.Node, what string) error {
+	return fmt.Errorf("esquery: %d: %s has no query DSL equivalent", n.Pos(), what)
+}
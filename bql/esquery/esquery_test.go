@@ -0,0 +1,130 @@
+package esquery_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/esquery"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// registerTestament registers testament, a field with no document field,
+// until the end of the test.
+func registerTestament(t *testing.T) {
+	t.Cleanup(parser.RegisterField(parser.FieldDef{Name: "testament", Type: parser.EnumValue, Operators: []state.ElementType{state.EQ}}))
+}
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// TestGolden translates the query of every testdata/*.bql file and compares
+// the request with the matching .json file.
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob("testdata/*.bql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files")
+	}
+
+	tr := esquery.Translator{BookSortField: "book_index"}
+	for _, name := range files {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q, err := parser.Parse(strings.TrimSpace(string(src)))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+		r, err := tr.Translate(q)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", name, err)
+		}
+
+		got, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, '\n')
+
+		golden := strings.TrimSuffix(name, ".bql") + ".json"
+		if *update {
+			if err := os.WriteFile(golden, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		expected, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("%s: expected\n%s\nbut got\n%s", name, expected, got)
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	registerTestament(t)
+	inputs := map[string]string{
+		"chapter = last()":                        "esquery: 10: function last has no query DSL equivalent",
+		"testament = new":                         "esquery: 0: no document field for field testament",
+		"text ~ love order by book":               "esquery: 21: sorting on book without Translator.BookSortField has no query DSL equivalent",
+		"count(text ~ love) limit 10":             "esquery: 0: count with order by, limit or offset has no query DSL equivalent",
+		"book in (john, last())":                  "esquery: 15: function last has no query DSL equivalent",
+		"book = john order by chapter, testament": "esquery: 30: no document field for field testament",
+		"text near(faith, works, 5, adjacent)":    "esquery: 5: near across adjacent verses has no query DSL equivalent",
+	}
+
+	for input, msg := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if _, err := esquery.Translate(q); err == nil || err.Error() != msg {
+			t.Fatalf("%q: expected error %q but got %v", input, msg, err)
+		}
+	}
+}
+
+// TestTranslateNumberContains checks trees that validation would reject, as
+// they can still be built by hand.
+func TestTranslateNumberContains(t *testing.T) {
+	q, err := parser.Parse(`text ~ "3"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c := q.Clause.(*parser.Comparison)
+	c.Value = &parser.NumberLiteral{Span: c.Value.(*parser.StringLiteral).Span, Int: 3}
+
+	const msg = "esquery: 7: non-string operand of ~ has no query DSL equivalent"
+	if _, err := esquery.Translate(q); err == nil || err.Error() != msg {
+		t.Fatalf("expected error %q but got %v", msg, err)
+	}
+}
+
+// TestTranslateMalformed checks that trees Parse does not produce are
+// rejected rather than translated.
+func TestTranslateMalformed(t *testing.T) {
+	s := &parser.StringLiteral{Span: parser.Span{Start: 8, Stop: 12}, Value: "john"}
+	queries := map[string]*parser.Query{
+		"esquery: 8: non-list operand of in has no query DSL equivalent":        {Clause: &parser.Comparison{Field: &parser.Field{Name: "book"}, Operator: state.IN, Value: s}},
+		"esquery: 8: non-proximity operand of near has no query DSL equivalent": {Clause: &parser.Comparison{Field: &parser.Field{Name: "text"}, Operator: state.NEAR, Value: s}},
+		"parser: 0: count() takes a single clause argument":                     {Clause: &parser.FunctionCall{Name: "count", Args: []parser.Node{s}}},
+	}
+
+	for msg, q := range queries {
+		if _, err := esquery.Translate(q); err == nil || err.Error() != msg {
+			t.Fatalf("expected error %q but got %v", msg, err)
+		}
+	}
+}
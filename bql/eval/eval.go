@@ -0,0 +1,410 @@
+// Package eval runs BQL queries against a corpus held in memory.
+//
+// Text comparisons match the verses holding the terms of the value as a
+// phrase, as split by the tokenizer of the index or index.Words: with the
+// default tokenizer, text = "love" and text ~ "love" match love but not loved,
+// and text = "world that he gave" matches "world, that he gave".
+//
+// Without an index, queries are compiled to a predicate on verses, which is
+// then tested on every verse of the corpus. With an index, see package index,
+// text comparisons are answered from the posting lists of the index and
+// clauses are combined with posting list operations, with the same results.
+// The near operator, which matches texts close to each other, requires an
+// index.
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/corpus"
+	"launchpad.net/kjvonly-bql/bql/index"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// Evaluator runs queries against a corpus.
+type Evaluator struct {
+	Corpus *corpus.Corpus
+
+	// Index, if set, is the index of Corpus used to answer the text
+	// comparisons.
+	Index *index.Index
+
+	// AcrossVerses lets the phrases of text comparisons run from a verse
+	// into the next ones, see Index.SearchAcross. It requires an index.
+	AcrossVerses bool
+}
+
+// Result is the outcome of a query.
+type Result struct {
+	// Verses holds the matching verses, sorted by the order by clause of
+	// the query or in canonical order, and limited by its limit and offset
+	// clauses.
+	Verses []corpus.Verse
+
+	// Count is true if the query asked for the number of matching verses
+	// rather than the verses, e.g. count(book = john).
+	Count bool
+}
+
+// matcher reports whether a verse matches a clause.
+type matcher func(v *corpus.Verse) bool
+
+// Evaluate runs q against c without an index.
+func Evaluate(q *parser.Query, c *corpus.Corpus) (*Result, error) {
+	e := Evaluator{Corpus: c}
+	return e.Evaluate(q)
+}
+
+// Evaluate runs q. q is the output of Parser.ParseQuery or Parse; field
+// aliases and references are resolved if validation has not done it. Evaluate
+// fails on the fields and functions it does not support, such as the last()
+// function, and if AcrossVerses is set without an index.
+func (e *Evaluator) Evaluate(q *parser.Query) (*Result, error) {
+	if e.AcrossVerses && e.Index == nil {
+		return nil, errors.New("eval: AcrossVerses requires an index")
+	}
+	cl, count, err := q.Selection()
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Count: count}
+
+	less, err := order(q.OrderBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Index != nil {
+		p, err := e.postings(cl)
+		if err != nil {
+			return nil, err
+		}
+		r.Verses = make([]corpus.Verse, 0, len(p))
+		for _, id := range p {
+			if v, ok := e.Corpus.Verse(id); ok {
+				r.Verses = append(r.Verses, *v)
+			}
+		}
+	} else {
+		m, err := e.clause(cl)
+		if err != nil {
+			return nil, err
+		}
+		vs := e.Corpus.Verses()
+		for i := range vs {
+			if m(&vs[i]) {
+				r.Verses = append(r.Verses, vs[i])
+			}
+		}
+	}
+
+	if less != nil {
+		sort.SliceStable(r.Verses, func(i, j int) bool {
+			return less(&r.Verses[i], &r.Verses[j])
+		})
+	}
+
+	if skip := q.Skip(); skip >= int64(len(r.Verses)) {
+		r.Verses = nil
+	} else if skip > 0 {
+		r.Verses = r.Verses[skip:]
+	}
+	if n, ok := q.MaxResults(); ok && n < int64(len(r.Verses)) {
+		r.Verses = r.Verses[:n]
+	}
+	return r, nil
+}
+
+func (e *Evaluator) clause(c parser.Clause) (matcher, error) {
+	switch c := c.(type) {
+	case *parser.OrClause:
+		ms, err := e.clauses(c.Clauses)
+		if err != nil {
+			return nil, err
+		}
+		return anyOf(ms), nil
+	case *parser.AndClause:
+		ms, err := e.clauses(c.Clauses)
+		if err != nil {
+			return nil, err
+		}
+		return func(v *corpus.Verse) bool {
+			for _, m := range ms {
+				if !m(v) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case *parser.NotClause:
+		m, err := e.clause(c.Clause)
+		if err != nil {
+			return nil, err
+		}
+		return not(m), nil
+	case *parser.Comparison:
+		return e.comparison(c)
+	case *parser.FunctionCall:
+		return nil, unsupported(c, "function "+c.Name)
+	}
+	return nil, unsupported(c, fmt.Sprintf("%T", c))
+}
+
+func (e *Evaluator) clauses(cs []parser.Clause) ([]matcher, error) {
+	ms := make([]matcher, len(cs))
+	for i, c := range cs {
+		m, err := e.clause(c)
+		if err != nil {
+			return nil, err
+		}
+		ms[i] = m
+	}
+	return ms, nil
+}
+
+func anyOf(ms []matcher) matcher {
+	return func(v *corpus.Verse) bool {
+		for _, m := range ms {
+			if m(v) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func not(m matcher) matcher {
+	return func(v *corpus.Verse) bool { return !m(v) }
+}
+
+// field returns the name of the registered field f refers to, which may be an
+// alias.
+func field(f *parser.Field) (string, error) {
+	def, ok := parser.LookupField(f.Name)
+	if !ok {
+		return "", fmt.Errorf("eval: %d: unknown field %s", f.Pos(), f.Name)
+	}
+	switch def.Name {
+	case "book", "chapter", "verse", "ref", "text":
+		return def.Name, nil
+	}
+	return "", unsupported(f, "field "+f.Name)
+}
+
+func (e *Evaluator) comparison(c *parser.Comparison) (matcher, error) {
+	name, err := field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Operator {
+	case state.NEAR:
+		return nil, unsupported(c.Value, "near without an index")
+	case state.IN, state.NOT_IN:
+		l, ok := c.Value.(*parser.List)
+		if !ok {
+			return nil, unsupported(c.Value, "non-list operand of in")
+		}
+		ms := make([]matcher, len(l.Values))
+		for i, o := range l.Values {
+			if ms[i], err = e.test(name, state.EQ, o); err != nil {
+				return nil, err
+			}
+		}
+		if c.Operator == state.NOT_IN {
+			return not(anyOf(ms)), nil
+		}
+		return anyOf(ms), nil
+	case state.NEQ:
+		m, err := e.test(name, state.EQ, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		return not(m), nil
+	case state.NOT_CONTAINS:
+		m, err := e.test(name, state.CONTAINS, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		return not(m), nil
+	}
+	return e.test(name, c.Operator, c.Value)
+}
+
+// test returns the matcher comparing the named field with o. op is one of EQ,
+// CONTAINS or the ordering operators; the negated operators are handled by
+// the caller.
+func (e *Evaluator) test(name string, op state.ElementType, o parser.Operand) (matcher, error) {
+	if f, ok := o.(*parser.FunctionCall); ok {
+		return nil, unsupported(f, "function "+f.Name)
+	}
+	if _, ok := o.(*parser.List); ok {
+		return nil, unsupported(o, "list operand of "+name)
+	}
+
+	switch name {
+	case "book":
+		if op != state.EQ {
+			break
+		}
+		id, err := book(o)
+		if err != nil {
+			return nil, err
+		}
+		return func(v *corpus.Verse) bool { return v.ID.Book() == id }, nil
+	case "chapter":
+		n, ok := o.(*parser.NumberLiteral)
+		if !ok {
+			return nil, fmt.Errorf("eval: %d: expected a number for field chapter", o.Pos())
+		}
+		return ordered(op, n, func(v *corpus.Verse) int { return v.ID.Chapter() })
+	case "verse":
+		n, ok := o.(*parser.NumberLiteral)
+		if !ok {
+			return nil, fmt.Errorf("eval: %d: expected a number for field verse", o.Pos())
+		}
+		return ordered(op, n, func(v *corpus.Verse) int { return v.ID.Verse() })
+	case "ref":
+		if op != state.EQ {
+			break
+		}
+		ids, err := ref(o)
+		if err != nil {
+			return nil, err
+		}
+		return func(v *corpus.Verse) bool {
+			i := sort.Search(len(ids), func(i int) bool { return ids[i] >= v.ID })
+			return i < len(ids) && ids[i] == v.ID
+		}, nil
+	case "text":
+		if op != state.EQ && op != state.CONTAINS {
+			break
+		}
+		s, ok := o.(*parser.StringLiteral)
+		if !ok {
+			return nil, fmt.Errorf("eval: %d: expected a string for field text", o.Pos())
+		}
+		t := e.tokenizer()
+		return func(v *corpus.Verse) bool { return index.Contains(t, v.Text, s.Value) }, nil
+	}
+	return nil, fmt.Errorf("eval: %d: operator not allowed for field %s", o.Pos(), name)
+}
+
+// tokenizer returns the tokenizer splitting the texts of text comparisons.
+func (e *Evaluator) tokenizer() index.Tokenizer {
+	if e.Index != nil {
+		return e.Index.Tokenizer()
+	}
+	return index.Words
+}
+
+// ordered returns the matcher comparing the number get returns with lit.
+func ordered(op state.ElementType, lit *parser.NumberLiteral, get func(v *corpus.Verse) int) (matcher, error) {
+	n := lit.Float64()
+	var cmp func(x float64) bool
+	switch op {
+	case state.EQ:
+		cmp = func(x float64) bool { return x == n }
+	case state.LT:
+		cmp = func(x float64) bool { return x < n }
+	case state.GT:
+		cmp = func(x float64) bool { return x > n }
+	case state.LTE:
+		cmp = func(x float64) bool { return x <= n }
+	case state.GTE:
+		cmp = func(x float64) bool { return x >= n }
+	default:
+		return nil, fmt.Errorf("eval: %d: operator not allowed for a number", lit.Pos())
+	}
+	return func(v *corpus.Verse) bool { return cmp(float64(get(v))) }, nil
+}
+
+// book returns the ID of the book o names or numbers.
+func book(o parser.Operand) (int, error) {
+	var name string
+	switch o := o.(type) {
+	case *parser.StringLiteral:
+		name = o.Value
+	case *parser.NumberLiteral:
+		if o.IsFloat {
+			return 0, fmt.Errorf("eval: %d: expected a book name or number", o.Pos())
+		}
+		name = strconv.FormatInt(o.Int, 10)
+	default:
+		return 0, fmt.Errorf("eval: %d: expected a string for field book", o.Pos())
+	}
+	id, ok := bible.ID(name)
+	if !ok {
+		return 0, fmt.Errorf("eval: %d: unknown book '%s'", o.Pos(), name)
+	}
+	return id, nil
+}
+
+// ref returns the sorted verses of a reference. Strings are parsed if
+// validation has not replaced them with RefLiterals.
+func ref(o parser.Operand) ([]bible.VerseID, error) {
+	switch o := o.(type) {
+	case *parser.RefLiteral:
+		return o.Verses, nil
+	case *parser.StringLiteral:
+		ids, err := bible.ParseReference(o.Value)
+		if err != nil {
+			return nil, fmt.Errorf("eval: %d: %v", o.Pos(), err)
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("eval: %d: expected a string for field ref", o.Pos())
+}
+
+// order returns the function sorting verses by keys, or nil to keep the
+// canonical order.
+func order(keys []*parser.SortKey) (func(a, b *corpus.Verse) bool, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	cmps := make([]func(a, b *corpus.Verse) int, len(keys))
+	for i, k := range keys {
+		name, err := field(k.Field)
+		if err != nil {
+			return nil, err
+		}
+		var cmp func(a, b *corpus.Verse) int
+		switch name {
+		case "book":
+			cmp = func(a, b *corpus.Verse) int { return a.ID.Book() - b.ID.Book() }
+		case "chapter":
+			cmp = func(a, b *corpus.Verse) int { return a.ID.Chapter() - b.ID.Chapter() }
+		case "verse":
+			cmp = func(a, b *corpus.Verse) int { return a.ID.Verse() - b.ID.Verse() }
+		case "ref":
+			cmp = func(a, b *corpus.Verse) int { return int(a.ID - b.ID) }
+		case "text":
+			cmp = func(a, b *corpus.Verse) int { return strings.Compare(a.Text, b.Text) }
+		}
+		if k.Descending {
+			asc := cmp
+			cmp = func(a, b *corpus.Verse) int { return asc(b, a) }
+		}
+		cmps[i] = cmp
+	}
+
+	return func(a, b *corpus.Verse) bool {
+		for _, cmp := range cmps {
+			if c := cmp(a, b); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	}, nil
+}
+
+func unsupported(n parser.Node, what string) error {
+	return fmt.Errorf("eval: %d: %s is not supported", n.Pos(), what)
+}
@@ -0,0 +1,263 @@
+package eval_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/corpus"
+	"launchpad.net/kjvonly-bql/bql/eval"
+	"launchpad.net/kjvonly-bql/bql/index"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// registerTestament registers testament, a field the evaluator knows nothing
+// about, until the end of the test.
+func registerTestament(t *testing.T) {
+	t.Cleanup(parser.RegisterField(parser.FieldDef{Name: "testament", Type: parser.EnumValue, Operators: []state.ElementType{state.EQ}}))
+}
+
+// load returns the fixture corpus of testdata/verses.json.
+func load(t *testing.T) *corpus.Corpus {
+	t.Helper()
+	f, err := os.Open("testdata/verses.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	c, err := corpus.Load(corpus.JSON, f)
+	var v *corpus.ValidationError
+	if err != nil && !errors.As(err, &v) {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// refs returns the references of vs, e.g. "John 3:16".
+func refs(vs []corpus.Verse) string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = v.ID.String()
+	}
+	return strings.Join(ss, ", ")
+}
+
+func TestEvaluate(t *testing.T) {
+	c := load(t)
+	tests := []struct {
+		input  string
+		verses string
+	}{
+		{`book = john`, "John 3:16, John 11:35, John 13:34"},
+		{`b = "1 jn"`, "1 John 4:8"},
+		{`book = 43`, "John 3:16, John 11:35, John 13:34"},
+		{`text = "LOVE"`, "Mark 12:30, Mark 12:31, John 13:34, 1 John 4:8"},
+		{`text = "love" and book = john`, "John 13:34"},
+		{`book = james or book = eph`, "Ephesians 2:8, Ephesians 2:9, James 2:17, James 2:26"},
+		{`text = faith and not text = works`, "1 Corinthians 13:13, Ephesians 2:8"},
+		{`!(book = genesis or text = "god") and text != "works"`, "Psalms 23:1, Mark 12:31, John 11:35, John 13:34, 1 Corinthians 13:13"},
+		{`text ~ commandment and text !~ "first"`, "Mark 12:31, John 13:34"},
+		{`book in (gen, ps) and not book = genesis`, "Psalms 23:1"},
+		{`book not in (gen, mark, john, "1 john", james, eph) and text ~ "god"`, "Romans 3:23, Jude 1:25"},
+		{`chapter >= 12 and verse < 31`, "Psalms 23:1, Mark 12:30, 1 Corinthians 13:13"},
+		{`chapter = 1 and verse in (1, 3, 25)`, "Genesis 1:1, Genesis 1:3, Jude 1:25"},
+		{`ref = "Gen 1:2-3" or ref in ("Jude 25", "John 11:35")`, "Genesis 1:2, Genesis 1:3, John 11:35, Jude 1:25"},
+		{`ref != "Gen 1, Mark 12, John 3:16-13:34" and text ~ "god"`, "Romans 3:23, Ephesians 2:8, 1 John 4:8, Jude 1:25"},
+		{`text = "dead" order by verse desc`, "James 2:26, James 2:17"},
+		{`book = gen or book = mark order by book desc, verse desc`, "Mark 12:31, Mark 12:30, Genesis 1:3, Genesis 1:2, Genesis 1:1"},
+		{`text = god order by text limit 2`, "Genesis 1:3, Genesis 1:2"},
+		{`text = god limit 2 offset 3`, "Mark 12:30, John 3:16"},
+		{`text = god limit 2 offset 30`, ""},
+		{`count(book = john)`, "John 3:16, John 11:35, John 13:34"},
+	}
+
+	for _, test := range tests {
+		q, err := parser.Parse(test.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", test.input, err)
+		}
+		r, err := eval.Evaluate(q, c)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", test.input, err)
+		}
+		if got := refs(r.Verses); got != test.verses {
+			t.Fatalf("%q: expected\n%s\nbut got\n%s", test.input, test.verses, got)
+		}
+		if r.Count != strings.HasPrefix(test.input, "count(") {
+			t.Fatalf("%q: unexpected count %v", test.input, r.Count)
+		}
+	}
+}
+
+func TestEvaluateIndex(t *testing.T) {
+	c := load(t)
+	e := eval.Evaluator{Corpus: c, Index: index.Build(c, nil)}
+	tests := []struct {
+		input  string
+		verses string
+	}{
+		// whole terms: love does not match loved or loveth
+		{`text = "LOVE"`, "Mark 12:30, Mark 12:31, John 13:34, 1 John 4:8"},
+		{`text ~ "one another"`, "John 13:34"},
+		{`text = "god so loved the world"`, "John 3:16"},
+		{`text = "the world god so loved"`, ""},
+		{`text = "Saviour be glory"`, "Jude 1:25"},
+		{`text = "of God for by grace"`, ""},
+		{`text = "love" and book = john`, "John 13:34"},
+		{`text = faith and not text = works`, "1 Corinthians 13:13, Ephesians 2:8"},
+		{`not text = god and not book = john and text != "faith"`, "Psalms 23:1, Mark 12:31, Ephesians 2:9"},
+		{`!(book = genesis or text = "god") and text != "works"`, "Psalms 23:1, Mark 12:31, John 11:35, John 13:34, 1 Corinthians 13:13"},
+		{`text ~ commandment and text !~ "first"`, "Mark 12:31, John 13:34"},
+		{`text = dead or ref = "Jude 25" or text = wept`, "John 11:35, James 2:17, James 2:26, Jude 1:25"},
+		{`text = "dead" order by verse desc limit 1`, "James 2:26"},
+		{`text = "!" or text = "jesus"`, "John 11:35"},
+		{`text near(faith, works, 5)`, "James 2:17, James 2:26"},
+		{`text near(works, "FAITH", 2)`, "James 2:26"},
+		{`text near(works, faith, 5, ordered)`, ""},
+		{`text near(god, love, 3) or text near(spirit, dead, 3)`, "James 2:26, 1 John 4:8"},
+		{`text near(love, "one another", 1, ordered) and not book = mark`, "John 13:34"},
+		{`text near("gift of god", works, 3)`, ""},
+		{`text near("gift of god", works, 3, adjacent)`, "Ephesians 2:8, Ephesians 2:9"},
+	}
+
+	for _, test := range tests {
+		q, err := parser.Parse(test.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", test.input, err)
+		}
+		r, err := e.Evaluate(q)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", test.input, err)
+		}
+		if got := refs(r.Verses); got != test.verses {
+			t.Fatalf("%q: expected\n%s\nbut got\n%s", test.input, test.verses, got)
+		}
+	}
+
+	e.AcrossVerses = true
+	across := map[string]string{
+		`text = "of God for by grace"`:           "", // not adjacent in the Bible
+		`text = "these three; but the greatest"`: "1 Corinthians 13:13",
+		`text = "gift of god not of works"`:      "Ephesians 2:8, Ephesians 2:9",
+	}
+	for input, verses := range across {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		r, err := e.Evaluate(q)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if got := refs(r.Verses); got != verses {
+			t.Fatalf("%q: expected\n%s\nbut got\n%s", input, verses, got)
+		}
+	}
+
+	q, err := parser.Parse(`text = love or chapter = last()`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Evaluate(q); err == nil || err.Error() != "eval: 25: function last is not supported" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	e.Index = nil
+	if _, err := e.Evaluate(q); err == nil || err.Error() != "eval: AcrossVerses requires an index" {
+		t.Fatalf("expected an error for AcrossVerses without an index but got %v", err)
+	}
+}
+
+// TestEvaluateIndexAgrees checks that the text comparisons match the same
+// verses with and without an index.
+func TestEvaluateIndexAgrees(t *testing.T) {
+	c := load(t)
+	ix := eval.Evaluator{Corpus: c, Index: index.Build(c, nil)}
+	inputs := []string{
+		`text ~ "love"`,
+		`text = "LOVED"`,
+		`text = "god so loved the world"`,
+		`text ~ "world that he gave"`,
+		`text = "the world god so loved"`,
+		`text != "faith" and book in (james, eph)`,
+		`not text ~ "the" or verse > 30`,
+		`text !~ "dead" and text ~ "works"`,
+		`text = "!" or text = ""`,
+	}
+
+	for _, input := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		scan, err := eval.Evaluate(q, c)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		r, err := ix.Evaluate(q)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if refs(scan.Verses) != refs(r.Verses) {
+			t.Fatalf("%q: matches\n%s\nwithout an index but\n%s\nwith an index", input, refs(scan.Verses), refs(r.Verses))
+		}
+	}
+}
+
+// TestEvaluateUnvalidated evaluates the unvalidated output of
+// Parser.ParseQuery, in which aliases and references are not resolved.
+func TestEvaluateUnvalidated(t *testing.T) {
+	c := load(t)
+	inputs := map[string]string{
+		`b = jn and t = "loved"`:   "John 3:16, John 13:34",
+		`b = 43 and t = "loved"`:   "John 3:16, John 13:34",
+		`reference = "Eph 2:8-10"`: "Ephesians 2:8, Ephesians 2:9",
+	}
+
+	for input, verses := range inputs {
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		p := parser.Parser{}
+		q, diags := p.ParseQuery(b)
+		if diags.HasErrors() {
+			t.Fatalf("%q: unexpected error: %s", input, diags)
+		}
+		r, err := eval.Evaluate(q, c)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if got := refs(r.Verses); got != verses {
+			t.Fatalf("%q: expected\n%s\nbut got\n%s", input, verses, got)
+		}
+	}
+}
+
+func TestEvaluateErrors(t *testing.T) {
+	registerTestament(t)
+	c := load(t)
+	inputs := map[string]string{
+		`testament = old`:                                      "eval: 0: field testament is not supported",
+		`book = john order by testament`:                       "eval: 21: field testament is not supported",
+		`chapter = last()`:                                     "eval: 10: function last is not supported",
+		`book = john and not chapter < 1.5 and verse = last()`: "eval: 46: function last is not supported",
+		`text near(faith, works, 5)`:                           "eval: 5: near without an index is not supported",
+	}
+
+	for input, msg := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		_, err = eval.Evaluate(q, c)
+		if err == nil || err.Error() != msg {
+			t.Fatalf("%q: expected error %q but got %v", input, msg, err)
+		}
+	}
+	// count of an operand, which Parse does not produce
+	q := &parser.Query{Clause: &parser.FunctionCall{Name: "count", Args: []parser.Node{&parser.StringLiteral{Value: "x"}}}}
+	if _, err := eval.Evaluate(q, c); err == nil || err.Error() != "parser: 0: count() takes a single clause argument" {
+		t.Fatalf("expected an error for count of an operand but got %v", err)
+	}
+}
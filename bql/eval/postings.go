@@ -0,0 +1,122 @@
+package eval
+
+import (
+	"fmt"
+
+	"launchpad.net/kjvonly-bql/bql/index"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// postings returns the verses matching c as a posting list. Text comparisons
+// are looked up in the index; the other comparisons are tested on every verse
+// of the corpus.
+func (e *Evaluator) postings(c parser.Clause) (index.PostingList, error) {
+	switch c := c.(type) {
+	case *parser.OrClause:
+		var p index.PostingList
+		for _, c := range c.Clauses {
+			q, err := e.postings(c)
+			if err != nil {
+				return nil, err
+			}
+			p = index.Union(p, q)
+		}
+		return p, nil
+	case *parser.AndClause:
+		// the negated clauses are subtracted from the intersection of the
+		// others rather than intersected with their complement
+		var (
+			p       index.PostingList
+			matched bool
+			negated []parser.Clause
+		)
+		for _, c := range c.Clauses {
+			if n, ok := c.(*parser.NotClause); ok {
+				negated = append(negated, n.Clause)
+				continue
+			}
+			q, err := e.postings(c)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				p = index.Intersect(p, q)
+			} else {
+				p, matched = q, true
+			}
+		}
+		if !matched {
+			p = e.Index.All()
+		}
+		for _, c := range negated {
+			q, err := e.postings(c)
+			if err != nil {
+				return nil, err
+			}
+			p = index.Difference(p, q)
+		}
+		return p, nil
+	case *parser.NotClause:
+		p, err := e.postings(c.Clause)
+		if err != nil {
+			return nil, err
+		}
+		return index.Difference(e.Index.All(), p), nil
+	case *parser.Comparison:
+		return e.comparisonPostings(c)
+	case *parser.FunctionCall:
+		return nil, unsupported(c, "function "+c.Name)
+	}
+	return nil, unsupported(c, fmt.Sprintf("%T", c))
+}
+
+func (e *Evaluator) comparisonPostings(c *parser.Comparison) (index.PostingList, error) {
+	name, err := field(c.Field)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "text" {
+		switch c.Operator {
+		case state.EQ, state.CONTAINS, state.NEQ, state.NOT_CONTAINS:
+			s, ok := c.Value.(*parser.StringLiteral)
+			if !ok {
+				return nil, fmt.Errorf("eval: %d: expected a string for field text", c.Value.Pos())
+			}
+			var p index.PostingList
+			if e.AcrossVerses {
+				p = e.Index.SearchAcross(s.Value)
+			} else {
+				p = e.Index.Search(s.Value)
+			}
+			if c.Operator == state.NEQ || c.Operator == state.NOT_CONTAINS {
+				p = index.Difference(e.Index.All(), p)
+			}
+			return p, nil
+		case state.NEAR:
+			x, ok := c.Value.(*parser.Proximity)
+			if !ok {
+				return nil, fmt.Errorf("eval: %d: expected a near operand", c.Value.Pos())
+			}
+			return e.Index.Near(x.First.Value, x.Second.Value, index.NearOptions{
+				Distance:     int(x.Distance.Int),
+				Ordered:      x.Ordered,
+				AcrossVerses: x.AcrossVerses,
+			}), nil
+		}
+	}
+
+	m, err := e.comparison(c)
+	if err != nil {
+		return nil, err
+	}
+	var p index.PostingList
+	vs := e.Corpus.Verses()
+	for i := range vs {
+		if m(&vs[i]) {
+			p = append(p, vs[i].ID)
+		}
+	}
+	return p, nil
+}
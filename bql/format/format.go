@@ -0,0 +1,227 @@
+// Package format prints BQL syntax trees as canonical BQL.
+//
+// Canonical BQL uses lower case keywords, the word form of the not operator,
+// double quoted strings, single spaces around binary operators and after
+// commas, and only the parentheses required by operator precedence. The output
+// of Node parses back to a tree that is structurally equal to its input, see
+// parser.Equal.
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+var operators = map[state.ElementType]string{
+	state.EQ:           "=",
+	state.NEQ:          "!=",
+	state.CONTAINS:     "~",
+	state.NOT_CONTAINS: "!~",
+	state.LT:           "<",
+	state.GT:           ">",
+	state.LTE:          "<=",
+	state.GTE:          ">=",
+	state.IN:           "in",
+	state.NOT_IN:       "not in",
+	state.NEAR:         "near",
+}
+
+// Binding strength of the clauses. A clause is parenthesized when it appears
+// in a clause of the same or higher precedence.
+const (
+	precOr = iota + 1
+	precAnd
+	precNot
+	precTerm
+)
+
+// Source parses a query and returns it in canonical form.
+func Source(query string) (string, error) {
+	q, err := parser.Parse(query)
+	if err != nil {
+		return "", err
+	}
+	return Node(q), nil
+}
+
+// Node returns the canonical form of the tree rooted at n.
+func Node(n parser.Node) string {
+	var sb strings.Builder
+	p := printer{&sb}
+	p.node(n)
+	return sb.String()
+}
+
+type printer struct {
+	sb *strings.Builder
+}
+
+func (p printer) node(n parser.Node) {
+	switch n := n.(type) {
+	case *parser.Query:
+		p.query(n)
+	case *parser.SortKey:
+		p.sortKey(n)
+	case parser.Clause:
+		p.clause(n, 0)
+	case parser.Operand:
+		p.operand(n)
+	case *parser.Field:
+		p.sb.WriteString(n.Name)
+	default:
+		panic(fmt.Sprintf("format: unexpected node type %T", n))
+	}
+}
+
+func (p printer) query(q *parser.Query) {
+	p.clause(q.Clause, 0)
+	for i, k := range q.OrderBy {
+		if i == 0 {
+			p.sb.WriteString(" order by ")
+		} else {
+			p.sb.WriteString(", ")
+		}
+		p.sortKey(k)
+	}
+	if q.Limit != nil {
+		p.sb.WriteString(" limit ")
+		p.number(q.Limit)
+	}
+	if q.Offset != nil {
+		p.sb.WriteString(" offset ")
+		p.number(q.Offset)
+	}
+}
+
+func (p printer) sortKey(k *parser.SortKey) {
+	p.sb.WriteString(k.Field.Name)
+	if k.Descending {
+		p.sb.WriteString(" desc")
+	}
+}
+
+// clause prints c, parenthesized if it binds less tightly than, or as tightly
+// as, the clause it appears in. Same-kind nesting such as a or (b or c) only
+// comes from explicit parentheses and keeping them preserves the tree.
+func (p printer) clause(c parser.Clause, outer int) {
+	prec := precedence(c)
+	if prec <= outer && prec != precNot {
+		p.sb.WriteByte('(')
+		defer p.sb.WriteByte(')')
+	}
+
+	switch c := c.(type) {
+	case *parser.OrClause:
+		p.clauses(c.Clauses, " or ", precOr)
+	case *parser.AndClause:
+		p.clauses(c.Clauses, " and ", precAnd)
+	case *parser.NotClause:
+		p.sb.WriteString("not ")
+		p.clause(c.Clause, precNot)
+	case *parser.Comparison:
+		p.sb.WriteString(c.Field.Name)
+		p.sb.WriteByte(' ')
+		p.sb.WriteString(operators[c.Operator])
+		if _, ok := c.Value.(*parser.Proximity); !ok {
+			p.sb.WriteByte(' ')
+		}
+		p.operand(c.Value)
+	case *parser.FunctionCall:
+		p.call(c)
+	default:
+		panic(fmt.Sprintf("format: unexpected clause type %T", c))
+	}
+}
+
+func (p printer) clauses(cs []parser.Clause, sep string, prec int) {
+	for i, c := range cs {
+		if i > 0 {
+			p.sb.WriteString(sep)
+		}
+		p.clause(c, prec)
+	}
+}
+
+func precedence(c parser.Clause) int {
+	switch c.(type) {
+	case *parser.OrClause:
+		return precOr
+	case *parser.AndClause:
+		return precAnd
+	case *parser.NotClause:
+		return precNot
+	}
+	return precTerm
+}
+
+func (p printer) operand(o parser.Operand) {
+	switch o := o.(type) {
+	case *parser.StringLiteral:
+		p.sb.WriteString(strconv.Quote(o.Value))
+	case *parser.RefLiteral:
+		p.sb.WriteString(strconv.Quote(o.Value))
+	case *parser.NumberLiteral:
+		p.number(o)
+	case *parser.FunctionCall:
+		p.call(o)
+	case *parser.Proximity:
+		// unordered and verse are the defaults
+		p.sb.WriteByte('(')
+		p.operand(o.First)
+		p.sb.WriteString(", ")
+		p.operand(o.Second)
+		p.sb.WriteString(", ")
+		p.number(o.Distance)
+		if o.Ordered {
+			p.sb.WriteString(", ordered")
+		}
+		if o.AcrossVerses {
+			p.sb.WriteString(", adjacent")
+		}
+		p.sb.WriteByte(')')
+	case *parser.List:
+		p.sb.WriteByte('(')
+		for i, v := range o.Values {
+			if i > 0 {
+				p.sb.WriteString(", ")
+			}
+			p.operand(v)
+		}
+		p.sb.WriteByte(')')
+	default:
+		panic(fmt.Sprintf("format: unexpected operand type %T", o))
+	}
+}
+
+func (p printer) number(n *parser.NumberLiteral) {
+	if !n.IsFloat {
+		p.sb.WriteString(strconv.FormatInt(n.Int, 10))
+		return
+	}
+	s := strconv.FormatFloat(n.Float, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// keep the literal a float
+		s += ".0"
+	}
+	p.sb.WriteString(s)
+}
+
+func (p printer) call(f *parser.FunctionCall) {
+	p.sb.WriteString(f.Name)
+	p.sb.WriteByte('(')
+	for i, a := range f.Args {
+		if i > 0 {
+			p.sb.WriteString(", ")
+		}
+		if c, ok := a.(parser.Clause); ok {
+			p.clause(c, 0)
+		} else {
+			p.node(a)
+		}
+	}
+	p.sb.WriteByte(')')
+}
@@ -0,0 +1,92 @@
+package format_test
+
+import (
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/format"
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input, expected string
+	}{
+		{`book=john`, `book = "john"`},
+		{`text = "say \"amen\""`, `text = "say \"amen\""`},
+		{`text~love  AND book!="john"`, `text ~ "love" and book != "john"`},
+		{`book = john OR text = love and verse > 3`, `book = "john" or text = "love" and verse > 3`},
+		{`(book = john or text = love) and verse > 3`, `(book = "john" or text = "love") and verse > 3`},
+		{`(book = john and text = love) or verse > 3`, `book = "john" and text = "love" or verse > 3`},
+		{`((book = john))`, `book = "john"`},
+		{`book = john or (text = love or verse > 3)`, `book = "john" or (text = "love" or verse > 3)`},
+		{`!book = john`, `not book = "john"`},
+		{`NOT (book = john)`, `not book = "john"`},
+		{`not (book = john and verse = 1)`, `not (book = "john" and verse = 1)`},
+		{`not not book = john`, `not not book = "john"`},
+		{`book NOT IN (john,mark)`, `book not in ("john", "mark")`},
+		{`chapter in (1, 2.5, 3e2)`, `chapter in (1, 2.5, 300.0)`},
+		{`verse = 1e30`, `verse = 1e+30`},
+		{`chapter = last()`, `chapter = last()`},
+		{`COUNT(book = john or text = love)`, `count(book = "john" or text = "love")`},
+		{`book = john ORDER BY book ASC, chapter DESC LIMIT 10 OFFSET 20`, `book = "john" order by book, chapter desc limit 10 offset 20`},
+		{`text NEAR (faith,works,5)`, `text near("faith", "works", 5)`},
+		{`text near(love, "one another", 3, ADJACENT, unordered)`, `text near("love", "one another", 3, adjacent)`},
+		{"book = john;", `book = "john"`},
+	}
+
+	for _, tt := range tests {
+		s, err := format.Source(tt.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", tt.input, err)
+		}
+		if s != tt.expected {
+			t.Fatalf("%q: expected %q but got %q", tt.input, tt.expected, s)
+		}
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	if _, err := format.Source("book = "); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+// TestRoundTrip checks that Parse(Format(Parse(q))) equals Parse(q) and that
+// formatting is idempotent.
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		`book = john`,
+		`book = john or text = love or verse = 3`,
+		`book = john and (text = love or text = hope) and not verse = 1`,
+		`(book = john and text = love) and verse = 1`,
+		`(book = john or (chapter = 2 or (verse = 3 or text = love)))`,
+		`not (not (book = john or book = mark))`,
+		`!(text ~ "a\tb\\c" and text !~ "é")`,
+		`book in (john) and chapter not in (1, 2) or verse <= 4.25`,
+		`verse >= .5 and verse < 1e-7 and chapter > 0x1f`,
+		`count(book = john and chapter = last())`,
+		`book = john order by book desc limit 0`,
+		`book = john offset 3`,
+		`ref in ("Gen 1:1-5", "Ps 23") and ref != "John 3:16"`,
+		`not text near(faith, "works", 5, ordered, adjacent) or text near(grace, faith, 1)`,
+	}
+
+	for _, input := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		s := format.Node(q)
+		q2, err := parser.Parse(s)
+		if err != nil {
+			t.Fatalf("%q: formatted as %q which does not parse: %s", input, s, err)
+		}
+		if !parser.Equal(q, q2) {
+			t.Fatalf("%q: formatted as %q which parses to a different tree", input, s)
+		}
+		if s2 := format.Node(q2); s2 != s {
+			t.Fatalf("%q: formatting is not idempotent: %q then %q", input, s, s2)
+		}
+	}
+}
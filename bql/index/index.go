@@ -0,0 +1,278 @@
+// Package index builds an inverted index of the text of a corpus, mapping
+// every term to the posting list of the verses it appears in and to its
+// positions in the text. Clauses on the text are then answered by combining
+// posting lists: intersections for and, unions for or and differences for
+// not. Positions let phrases be matched in order, and near one another.
+package index
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/emirpasic/gods/trees/redblacktree"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/corpus"
+)
+
+// Index is an inverted index of the verses of a corpus. It is safe for
+// concurrent use once built.
+//
+// The terms of the corpus are numbered in order, from the first term of the
+// first verse to the last term of the last verse; the position of a term is
+// its number. A term is thus followed by the next term of its verse, or by the
+// first term of the next verse.
+type Index struct {
+	tokenizer Tokenizer
+	terms     *redblacktree.Tree // term to *entry, in term order
+	all       PostingList
+	offsets   []int // position of the first term of every verse of all
+	size      int   // number of terms of the corpus
+}
+
+// entry holds the verses and the positions of a term.
+type entry struct {
+	verses    PostingList
+	positions []int
+}
+
+// Build indexes the verses of c, splitting their text with t. Words is used
+// if t is nil.
+func Build(c *corpus.Corpus, t Tokenizer) *Index {
+	if t == nil {
+		t = Words
+	}
+	ix := &Index{
+		tokenizer: t,
+		terms:     redblacktree.NewWithStringComparator(),
+		all:       make(PostingList, 0, c.Len()),
+		offsets:   make([]int, 0, c.Len()),
+	}
+
+	// verses come in canonical order, so appending keeps the lists sorted
+	for _, v := range c.Verses() {
+		ix.all = append(ix.all, v.ID)
+		ix.offsets = append(ix.offsets, ix.size)
+		for _, term := range t.Tokenize(v.Text) {
+			var e *entry
+			if value, ok := ix.terms.Get(term); ok {
+				e = value.(*entry)
+			} else {
+				e = &entry{}
+				ix.terms.Put(term, e)
+			}
+			if n := len(e.verses); n == 0 || e.verses[n-1] != v.ID {
+				e.verses = append(e.verses, v.ID)
+			}
+			e.positions = append(e.positions, ix.size)
+			ix.size++
+		}
+	}
+	return ix
+}
+
+// Tokenizer returns the tokenizer the index was built with.
+func (ix *Index) Tokenizer() Tokenizer { return ix.tokenizer }
+
+// All returns every verse of the indexed corpus.
+func (ix *Index) All() PostingList { return ix.all }
+
+// Len returns the number of distinct terms of the index.
+func (ix *Index) Len() int { return ix.terms.Size() }
+
+// Postings returns the verses containing term, which must be a term as
+// returned by the tokenizer.
+func (ix *Index) Postings(term string) PostingList {
+	if e := ix.entry(term); e != nil {
+		return e.verses
+	}
+	return nil
+}
+
+// Positions returns the sorted positions of term.
+func (ix *Index) Positions(term string) []int {
+	if e := ix.entry(term); e != nil {
+		return e.positions
+	}
+	return nil
+}
+
+func (ix *Index) entry(term string) *entry {
+	if value, ok := ix.terms.Get(term); ok {
+		return value.(*entry)
+	}
+	return nil
+}
+
+// Search returns the verses containing the terms of text as a phrase: in
+// order and next to each other, whatever the punctuation between them. It
+// returns no verses if text has no terms.
+func (ix *Index) Search(text string) PostingList {
+	return ix.phrase(ix.tokenizer.Tokenize(text), false)
+}
+
+// SearchAcross is like Search, but also matches the phrases running from the
+// end of a verse into the next ones, in which case every verse of the phrase
+// is returned. The verses must follow each other in the Bible: the verses
+// missing from the corpus end the phrases.
+func (ix *Index) SearchAcross(text string) PostingList {
+	return ix.phrase(ix.tokenizer.Tokenize(text), true)
+}
+
+func (ix *Index) phrase(terms []string, across bool) PostingList {
+	if len(terms) == 0 {
+		return nil
+	}
+	if len(terms) == 1 {
+		return ix.Postings(terms[0])
+	}
+
+	var p PostingList
+	for _, start := range ix.starts(terms) {
+		p = ix.span(p, start, start+len(terms)-1, across)
+	}
+	return p
+}
+
+// starts returns the positions of the phrases of terms, which are those of
+// their first term.
+func (ix *Index) starts(terms []string) []int {
+	starts := ix.Positions(terms[0])
+	for i := 1; i < len(terms) && len(starts) > 0; i++ {
+		starts = follow(starts, ix.Positions(terms[i]), i)
+	}
+	return starts
+}
+
+// span appends to p the verses holding the terms from position start to
+// position end, unless they run across verses that are not adjacent. Spans
+// must be appended in order of their start.
+func (ix *Index) span(p PostingList, start, end int, across bool) PostingList {
+	first, last := ix.verseAt(start), ix.verseAt(end)
+	if first != last && !ix.adjacent(first, last, across) {
+		return p
+	}
+	for i := first; i <= last; i++ {
+		if n := len(p); n == 0 || p[n-1] < ix.all[i] {
+			p = append(p, ix.all[i])
+		}
+	}
+	return p
+}
+
+// NearOptions are the options of Near.
+type NearOptions struct {
+	// Distance is the largest number of positions from the last term of a
+	// phrase to the first term of the other: 1 if they follow each other.
+	Distance int
+
+	// Ordered requires the first phrase to come before the second one.
+	Ordered bool
+
+	// AcrossVerses lets the phrases run into the verses that follow in the
+	// Bible, as with SearchAcross.
+	AcrossVerses bool
+}
+
+// Near returns the verses containing the phrases a and b at most o.Distance
+// positions apart, in any order unless o.Ordered is set. If the phrases are
+// in different verses, every verse from the first phrase to the second one is
+// returned. It returns no verses if a or b has no terms.
+func (ix *Index) Near(a, b string, o NearOptions) PostingList {
+	ta, tb := ix.tokenizer.Tokenize(a), ix.tokenizer.Tokenize(b)
+	if len(ta) == 0 || len(tb) == 0 || o.Distance < 1 {
+		return nil
+	}
+	sa, sb := ix.starts(ta), ix.starts(tb)
+
+	type interval struct{ start, end int }
+	var spans []interval
+	// within appends the spans from a phrase of n terms at every position
+	// of starts to a following phrase of m terms at a position of next.
+	within := func(starts []int, n int, next []int, m int) {
+		for _, s := range starts {
+			end := s + n - 1
+			for j := sort.SearchInts(next, end+1); j < len(next) && next[j] <= end+o.Distance; j++ {
+				spans = append(spans, interval{s, next[j] + m - 1})
+			}
+		}
+	}
+	within(sa, len(ta), sb, len(tb))
+	if !o.Ordered {
+		within(sb, len(tb), sa, len(ta))
+		sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	}
+
+	var p PostingList
+	for _, s := range spans {
+		p = ix.span(p, s.start, s.end, o.AcrossVerses)
+	}
+	return p
+}
+
+// follow returns the positions of starts followed, d positions further, by a
+// position of next.
+func follow(starts, next []int, d int) []int {
+	var ps []int
+	j := 0
+	for _, s := range starts {
+		for j < len(next) && next[j] < s+d {
+			j++
+		}
+		if j < len(next) && next[j] == s+d {
+			ps = append(ps, s)
+		}
+	}
+	return ps
+}
+
+// adjacent reports whether a phrase may run from the verse at index first of
+// all to the verse at index last.
+func (ix *Index) adjacent(first, last int, across bool) bool {
+	if !across {
+		return false
+	}
+	for i := first; i < last; i++ {
+		if next(ix.all[i]) != ix.all[i+1] {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the verse following v in the Bible.
+func next(v bible.VerseID) bible.VerseID {
+	b, c := v.Book(), v.Chapter()
+	switch {
+	case v.Verse() < bible.Verses(b, c):
+		return v + 1
+	case c < bible.Chapters(b):
+		return bible.NewVerseID(b, c+1, 1)
+	}
+	return bible.NewVerseID(b+1, 1, 1)
+}
+
+// verseAt returns the index in all of the verse holding the term at pos.
+func (ix *Index) verseAt(pos int) int {
+	return sort.Search(len(ix.offsets), func(i int) bool { return ix.offsets[i] > pos }) - 1
+}
+
+// Terms returns the terms of the index starting with prefix, in order.
+func (ix *Index) Terms(prefix string) []string {
+	var terms []string
+	node, _ := ix.terms.Ceiling(prefix)
+	if node == nil {
+		return nil
+	}
+	for it := ix.terms.IteratorAt(node); ; {
+		term := it.Key().(string)
+		if !strings.HasPrefix(term, prefix) {
+			break
+		}
+		terms = append(terms, term)
+		if !it.Next() {
+			break
+		}
+	}
+	return terms
+}
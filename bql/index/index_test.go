@@ -0,0 +1,229 @@
+package index_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/corpus"
+	"launchpad.net/kjvonly-bql/bql/index"
+)
+
+func TestSetOperations(t *testing.T) {
+	a := index.PostingList{1, 3, 5, 7, 9}
+	b := index.PostingList{2, 3, 4, 9, 10, 11}
+	tests := []struct {
+		name     string
+		got, exp index.PostingList
+	}{
+		{"intersect", index.Intersect(a, b), index.PostingList{3, 9}},
+		{"union", index.Union(a, b), index.PostingList{1, 2, 3, 4, 5, 7, 9, 10, 11}},
+		{"difference", index.Difference(a, b), index.PostingList{1, 5, 7}},
+		{"difference", index.Difference(b, a), index.PostingList{2, 4, 10, 11}},
+		{"intersect empty", index.Intersect(a, nil), nil},
+		{"union empty", index.Union(nil, b), b},
+		{"difference empty", index.Difference(a, nil), a},
+	}
+
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.got, test.exp) {
+			t.Fatalf("%s: expected %v but got %v", test.name, test.exp, test.got)
+		}
+	}
+
+	if !a.Contains(7) || a.Contains(8) || a.Contains(10) {
+		t.Fatalf("unexpected Contains results for %v", a)
+	}
+}
+
+// verses are indexed by the tests unless they provide their own.
+var verses = []corpus.Verse{
+	{ID: 43003016, Text: "For God so loved the world, that he gave his only begotten Son"},
+	{ID: 43011035, Text: "Jesus wept."},
+	{ID: 62004008, Text: "He that loveth not knoweth not God; for God is love."},
+	{ID: 19023001, Text: "The LORD is my shepherd; I shall not want."},
+}
+
+func build(t *testing.T, tok index.Tokenizer, vs ...corpus.Verse) *index.Index {
+	t.Helper()
+	if len(vs) == 0 {
+		vs = verses
+	}
+	c, _ := corpus.New(vs)
+	if c.Len() != len(vs) {
+		t.Fatalf("expected %d verses but got %d", len(vs), c.Len())
+	}
+	return index.Build(c, tok)
+}
+
+func TestBuild(t *testing.T) {
+	ix := build(t, nil)
+
+	tests := map[string]index.PostingList{
+		"god":     {43003016, 62004008},
+		"not":     {19023001, 62004008},
+		"lord":    {19023001},
+		"love":    {62004008},
+		"LORD":    nil,
+		"charity": nil,
+	}
+	for term, exp := range tests {
+		if got := ix.Postings(term); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("%q: expected %v but got %v", term, exp, got)
+		}
+	}
+
+	if ix.Len() != 26 || len(ix.All()) != 4 || ix.All()[0] != bible.NewVerseID(19, 23, 1) {
+		t.Fatalf("unexpected index of %d terms and verses %v", ix.Len(), ix.All())
+	}
+
+	if got := strings.Join(ix.Terms("lo"), " "); got != "lord love loved loveth" {
+		t.Fatalf("expected terms starting with lo but got %q", got)
+	}
+	if got := ix.Terms("zz"); got != nil {
+		t.Fatalf("expected no terms but got %q", got)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ix := build(t, nil)
+
+	tests := map[string]index.PostingList{
+		"God":                    {43003016, 62004008},
+		"not god":                {62004008},
+		"god not":                nil,
+		"God so loved the world": {43003016},
+		"so the world":           nil,
+		"world that he gave":     {43003016},
+		"god: for GOD":           {62004008},
+		"begotten son jesus":     nil,
+		"":                       nil,
+		"!?":                     nil,
+	}
+	for text, exp := range tests {
+		if got := ix.Search(text); !reflect.DeepEqual(got, exp) {
+			t.Fatalf("%q: expected %v but got %v", text, exp, got)
+		}
+	}
+}
+
+func TestSearchAcross(t *testing.T) {
+	ix := build(t, nil,
+		corpus.Verse{ID: 1001001, Text: "In the beginning God created the heaven and the earth."},
+		corpus.Verse{ID: 1001002, Text: "And the earth was without form, and void;"},
+		corpus.Verse{ID: 1001003, Text: ""},
+		corpus.Verse{ID: 1001004, Text: "And God saw the light, that it was good:"},
+	)
+
+	tests := []struct {
+		text           string
+		search, across index.PostingList
+	}{
+		{"the earth", index.PostingList{1001001, 1001002}, index.PostingList{1001001, 1001002}},
+		{"the earth and the earth", nil, index.PostingList{1001001, 1001002}},
+		{"earth and the", nil, index.PostingList{1001001, 1001002}},
+		{"void and god", nil, index.PostingList{1001002, 1001003, 1001004}},
+		{"god saw", index.PostingList{1001004}, index.PostingList{1001004}},
+		{"good in", nil, nil},
+	}
+	for _, test := range tests {
+		if got := ix.Search(test.text); !reflect.DeepEqual(got, test.search) {
+			t.Fatalf("%q: expected %v but got %v", test.text, test.search, got)
+		}
+		if got := ix.SearchAcross(test.text); !reflect.DeepEqual(got, test.across) {
+			t.Fatalf("%q: expected %v across verses but got %v", test.text, test.across, got)
+		}
+	}
+
+	// John 3:16 and John 11:35 do not follow each other
+	if got := build(t, nil).SearchAcross("begotten son jesus"); got != nil {
+		t.Fatalf("expected no verses but got %v", got)
+	}
+	// the last verse of Malachi is followed by the first of Matthew
+	mal := build(t, nil,
+		corpus.Verse{ID: 39004006, Text: "lest I come and smite the earth with a curse."},
+		corpus.Verse{ID: 40001001, Text: "The book of the generation of Jesus Christ,"},
+	)
+	if got, exp := mal.SearchAcross("curse the book"), (index.PostingList{39004006, 40001001}); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v but got %v", exp, got)
+	}
+
+	if got := ix.Positions("earth"); !reflect.DeepEqual(got, []int{9, 12}) {
+		t.Fatalf("expected the positions of earth but got %v", got)
+	}
+}
+
+func TestNear(t *testing.T) {
+	ix := build(t, nil,
+		corpus.Verse{ID: 1001001, Text: "In the beginning God created the heaven and the earth."},
+		corpus.Verse{ID: 1001002, Text: "And the earth was without form, and void;"},
+		corpus.Verse{ID: 1001003, Text: ""},
+		corpus.Verse{ID: 1001004, Text: "And God saw the light, that it was good:"},
+	)
+
+	tests := []struct {
+		a, b     string
+		o        index.NearOptions
+		expected index.PostingList
+	}{
+		{"god", "heaven", index.NearOptions{Distance: 3}, index.PostingList{1001001}},
+		{"god", "heaven", index.NearOptions{Distance: 2}, nil},
+		{"heaven", "god", index.NearOptions{Distance: 3}, index.PostingList{1001001}},
+		{"heaven", "god", index.NearOptions{Distance: 3, Ordered: true}, nil},
+		{"EARTH", "void", index.NearOptions{Distance: 5, Ordered: true}, index.PostingList{1001002}},
+		{"god", "the", index.NearOptions{Distance: 2}, index.PostingList{1001001, 1001004}},
+		{"void", "god saw", index.NearOptions{Distance: 2}, nil},
+		{"void", "god saw", index.NearOptions{Distance: 2, AcrossVerses: true}, index.PostingList{1001002, 1001003, 1001004}},
+		{"the earth", "and the", index.NearOptions{Distance: 1}, nil},
+		{"the earth", "and the", index.NearOptions{Distance: 1, Ordered: true, AcrossVerses: true}, index.PostingList{1001001, 1001002}},
+		{"", "god", index.NearOptions{Distance: 5}, nil},
+		{"god", "heaven", index.NearOptions{}, nil},
+	}
+	for _, test := range tests {
+		if got := ix.Near(test.a, test.b, test.o); !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("%q near %q %+v: expected %v but got %v", test.a, test.b, test.o, test.expected, got)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		text, phrase string
+		expected     bool
+	}{
+		{"For God so loved the world, that he gave", "world that he", true},
+		{"For God so loved the world, that he gave", "LOVED", true},
+		{"For God so loved the world, that he gave", "love", false},
+		{"For God so loved the world, that he gave", "the world god", false},
+		{"For God so loved the world, that he gave", "gave.", true},
+		{"For God so loved the world, that he gave", "", false},
+	}
+	for _, test := range tests {
+		if got := index.Contains(index.Words, test.text, test.phrase); got != test.expected {
+			t.Fatalf("%q in %q: expected %v", test.phrase, test.text, test.expected)
+		}
+	}
+}
+
+func TestTokenizer(t *testing.T) {
+	// index the prefixes of words so that love matches loved and loveth
+	prefixes := index.TokenizerFunc(func(text string) []string {
+		var terms []string
+		for _, w := range index.Words.Tokenize(text) {
+			for i := 3; i <= len(w); i++ {
+				terms = append(terms, w[:i])
+			}
+		}
+		return terms
+	})
+	ix := build(t, prefixes)
+
+	exp := index.PostingList{43003016, 62004008}
+	if got := ix.Search("love"); !reflect.DeepEqual(got, exp) {
+		t.Fatalf("expected %v but got %v", exp, got)
+	}
+	if ix.Tokenizer() == nil {
+		t.Fatalf("expected the tokenizer of the index")
+	}
+}
@@ -0,0 +1,71 @@
+package index
+
+import (
+	"sort"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+)
+
+// PostingList is a sorted list of distinct verses.
+type PostingList []bible.VerseID
+
+// Contains reports whether id is in p.
+func (p PostingList) Contains(id bible.VerseID) bool {
+	i := sort.Search(len(p), func(i int) bool { return p[i] >= id })
+	return i < len(p) && p[i] == id
+}
+
+// Intersect returns the verses in both a and b.
+func Intersect(a, b PostingList) PostingList {
+	var p PostingList
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			p = append(p, a[i])
+			i++
+			j++
+		}
+	}
+	return p
+}
+
+// Union returns the verses in a, b or both.
+func Union(a, b PostingList) PostingList {
+	p := make(PostingList, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			p = append(p, a[i])
+			i++
+		case a[i] > b[j]:
+			p = append(p, b[j])
+			j++
+		default:
+			p = append(p, a[i])
+			i++
+			j++
+		}
+	}
+	p = append(p, a[i:]...)
+	return append(p, b[j:]...)
+}
+
+// Difference returns the verses in a that are not in b.
+func Difference(a, b PostingList) PostingList {
+	var p PostingList
+	j := 0
+	for _, id := range a {
+		for j < len(b) && b[j] < id {
+			j++
+		}
+		if j == len(b) || b[j] != id {
+			p = append(p, id)
+		}
+	}
+	return p
+}
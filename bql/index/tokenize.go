@@ -0,0 +1,48 @@
+package index
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenizer splits a text into the terms it is indexed under. The same
+// tokenizer splits the texts of the verses and of the queries.
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface.
+type TokenizerFunc func(text string) []string
+
+func (f TokenizerFunc) Tokenize(text string) []string { return f(text) }
+
+// Words is the default tokenizer. Its terms are the lower case runs of letters
+// and digits of a text, so that "LORD'S" is indexed under lord and s.
+var Words Tokenizer = TokenizerFunc(words)
+
+// Contains reports whether text holds the terms of phrase, as split by t, in
+// order and next to each other, which is how Search matches the text of a
+// verse. It is false if phrase has no terms.
+func Contains(t Tokenizer, text, phrase string) bool {
+	terms := t.Tokenize(phrase)
+	if len(terms) == 0 {
+		return false
+	}
+	ts := t.Tokenize(text)
+outer:
+	for i := 0; i+len(terms) <= len(ts); i++ {
+		for j, term := range terms {
+			if ts[i+j] != term {
+				continue outer
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,351 @@
+// Package normalize rewrites the clauses of a query into a simpler, equivalent
+// form.
+//
+// The rewrite pushes negations down to the comparisons with De Morgan's laws
+// and removes double negations, flattens nested and/or clauses, removes
+// duplicate clauses, applies the absorption laws, e.g. a or (a and b) is a,
+// and folds equality tests of a field into in lists:
+//
+//	book = john or book = mark        =>  book in ("john", "mark")
+//	book != john and book != mark     =>  book not in ("john", "mark")
+//
+// It can further convert the clauses to disjunctive or conjunctive normal
+// form. The rewritten tree may share nodes with the original one, which is
+// never modified. The spans of new nodes cover the spans of their children.
+package normalize
+
+import (
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// Form is a normal form of the clauses of a query.
+type Form int
+
+const (
+	// NNF leaves the clauses in negation normal form: negations only apply
+	// to comparisons and function calls.
+	NNF Form = iota
+	// DNF converts the clauses to an or of ands.
+	DNF
+	// CNF converts the clauses to an and of ors.
+	CNF
+)
+
+// Options configure the rewrite.
+type Options struct {
+	// Form is the normal form of the result. Note that the conversion to DNF
+	// or CNF can make the query exponentially larger.
+	Form Form
+}
+
+// Query returns a copy of q whose clause is normalized.
+func Query(q *parser.Query, opts Options) *parser.Query {
+	n := *q
+	n.Clause = Clause(q.Clause, opts)
+	return &n
+}
+
+// Clause returns the normalized form of c. The clause arguments of function
+// calls, e.g. count(...), are normalized too, always to NNF.
+func Clause(c parser.Clause, opts Options) parser.Clause {
+	if opts.Form == NNF {
+		return simplify(nnf(c, false), true)
+	}
+
+	// in lists would hide terms from the distribution, fold them last
+	c = simplify(nnf(c, false), false)
+	return simplify(distribute(c, opts.Form == CNF), true)
+}
+
+// nnf pushes the negations of c down to its leaves. If negate is true, it
+// returns the negation of c.
+func nnf(c parser.Clause, negate bool) parser.Clause {
+	switch c := c.(type) {
+	case *parser.NotClause:
+		return nnf(c.Clause, !negate)
+	case *parser.AndClause, *parser.OrClause:
+		cs := children(c)
+		ns := make([]parser.Clause, len(cs))
+		for i, cc := range cs {
+			ns[i] = nnf(cc, negate)
+		}
+		return junction(ns, isAnd(c) != negate)
+	case *parser.FunctionCall:
+		c = normalizeArgs(c)
+		if negate {
+			return &parser.NotClause{Span: c.Span, Clause: c}
+		}
+		return c
+	}
+
+	if negate {
+		return &parser.NotClause{Span: parser.Span{Start: c.Pos(), Stop: c.End()}, Clause: c}
+	}
+	return c
+}
+
+// normalizeArgs returns a copy of f whose clause arguments are normalized.
+func normalizeArgs(f *parser.FunctionCall) *parser.FunctionCall {
+	n := *f
+	n.Args = make([]parser.Node, len(f.Args))
+	for i, a := range f.Args {
+		if c, ok := a.(parser.Clause); ok {
+			a = Clause(c, Options{})
+		}
+		n.Args[i] = a
+	}
+	return &n
+}
+
+// simplify flattens, deduplicates, absorbs and, if fold is true, folds the
+// and/or clauses of an NNF tree, bottom up.
+func simplify(c parser.Clause, fold bool) parser.Clause {
+	if !isJunction(c) {
+		return c
+	}
+
+	and := isAnd(c)
+	var flat []parser.Clause
+	for _, cc := range children(c) {
+		cc = simplify(cc, fold)
+		if isJunction(cc) && isAnd(cc) == and {
+			flat = append(flat, children(cc)...)
+		} else {
+			flat = append(flat, cc)
+		}
+	}
+
+	flat = absorb(dedup(flat), and)
+	if fold {
+		flat = foldLists(flat, and)
+	}
+	return junction(flat, and)
+}
+
+// distribute converts an NNF tree to DNF, or to CNF if cnf is true, by
+// distributing and over or (or over and).
+func distribute(c parser.Clause, cnf bool) parser.Clause {
+	if !isJunction(c) {
+		return c
+	}
+
+	// inner is the kind of the clauses the result is made of: ands for DNF
+	// and ors for CNF.
+	inner := !cnf
+	cs := children(c)
+	if isAnd(c) != inner {
+		// an or of DNFs is a DNF, once flattened
+		var ds []parser.Clause
+		for _, cc := range cs {
+			ds = append(ds, terms(distribute(cc, cnf), !inner)...)
+		}
+		return junction(ds, !inner)
+	}
+
+	// the product of the terms of every child, e.g. for DNF
+	// (a or b) and (c or d) is (a and c) or (a and d) or (b and c) or (b and d)
+	products := [][]parser.Clause{nil}
+	for _, cc := range cs {
+		var next [][]parser.Clause
+		for _, p := range products {
+			for _, t := range terms(distribute(cc, cnf), !inner) {
+				next = append(next, append(p[:len(p):len(p)], children(t)...))
+			}
+		}
+		products = next
+	}
+
+	res := make([]parser.Clause, len(products))
+	for i, p := range products {
+		res[i] = junction(p, inner)
+	}
+	return junction(res, !inner)
+}
+
+func dedup(cs []parser.Clause) []parser.Clause {
+	var res []parser.Clause
+	for _, c := range cs {
+		if indexOf(res, c) < 0 {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// absorb removes the children of an and (or) clause that are ors (ands)
+// including all the terms of another child, e.g. a and (a or b) is a.
+func absorb(cs []parser.Clause, and bool) []parser.Clause {
+	var res []parser.Clause
+outer:
+	for i, c := range cs {
+		if isJunction(c) && isAnd(c) != and {
+			for j, o := range cs {
+				if i != j && subset(terms(o, !and), children(c)) && !(subset(children(c), terms(o, !and)) && j > i) {
+					continue outer
+				}
+			}
+		}
+		res = append(res, c)
+	}
+	return res
+}
+
+// terms returns c as a list of clauses to be joined with and (or): the
+// children of c if it is an and (or) clause, or c itself.
+func terms(c parser.Clause, and bool) []parser.Clause {
+	if isJunction(c) && isAnd(c) == and {
+		return children(c)
+	}
+	return []parser.Clause{c}
+}
+
+// subset reports whether every clause of a is in b.
+func subset(a, b []parser.Clause) bool {
+	for _, c := range a {
+		if indexOf(b, c) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// foldLists merges the = and in comparisons on a field of the children of an or
+// clause into a single in comparison, and the != and not in comparisons of
+// the children of an and clause into a single not in comparison.
+func foldLists(cs []parser.Clause, and bool) []parser.Clause {
+	single, list := state.EQ, state.IN
+	if and {
+		single, list = state.NEQ, state.NOT_IN
+	}
+
+	counts := map[string]int{}
+	for _, c := range cs {
+		if f, ok := foldable(c, single, list); ok {
+			counts[f]++
+		}
+	}
+
+	var res []parser.Clause
+	merged := map[string]*parser.Comparison{}
+	for _, c := range cs {
+		f, ok := foldable(c, single, list)
+		if !ok || counts[f] < 2 {
+			res = append(res, c)
+			continue
+		}
+
+		cmp := c.(*parser.Comparison)
+		m, ok := merged[f]
+		if !ok {
+			m = &parser.Comparison{Span: cmp.Span, Field: cmp.Field, Operator: list, Value: &parser.List{Span: span(cmp.Value)}}
+			merged[f] = m
+			res = append(res, m)
+		}
+
+		l := m.Value.(*parser.List)
+		for _, v := range values(cmp) {
+			if !containsValue(l.Values, v) {
+				l.Values = append(l.Values, v)
+			}
+		}
+		m.Span = cover(m.Span, cmp.Span)
+		l.Span = cover(l.Span, span(cmp.Value))
+	}
+	return res
+}
+
+// foldable returns the name of the field of c if c is a comparison with the
+// single or list operator and literal operands, on a field that accepts the
+// list operator.
+func foldable(c parser.Clause, single, list state.ElementType) (string, bool) {
+	cmp, ok := c.(*parser.Comparison)
+	if !ok || (cmp.Operator != single && cmp.Operator != list) {
+		return "", false
+	}
+	if def, ok := parser.LookupField(cmp.Field.Name); !ok || !def.Allows(list) {
+		return "", false
+	}
+	for _, v := range values(cmp) {
+		switch v.(type) {
+		case *parser.StringLiteral, *parser.NumberLiteral:
+		default:
+			return "", false
+		}
+	}
+	return cmp.Field.Name, true
+}
+
+// values returns the operands of a comparison, i.e. the values of its list.
+func values(c *parser.Comparison) []parser.Operand {
+	if l, ok := c.Value.(*parser.List); ok {
+		return l.Values
+	}
+	return []parser.Operand{c.Value}
+}
+
+func containsValue(vs []parser.Operand, v parser.Operand) bool {
+	for _, o := range vs {
+		if parser.Equal(o, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(cs []parser.Clause, c parser.Clause) int {
+	for i, o := range cs {
+		if parser.Equal(o, c) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isJunction(c parser.Clause) bool {
+	switch c.(type) {
+	case *parser.AndClause, *parser.OrClause:
+		return true
+	}
+	return false
+}
+
+func isAnd(c parser.Clause) bool {
+	_, ok := c.(*parser.AndClause)
+	return ok
+}
+
+func children(c parser.Clause) []parser.Clause {
+	switch c := c.(type) {
+	case *parser.AndClause:
+		return c.Clauses
+	case *parser.OrClause:
+		return c.Clauses
+	}
+	return []parser.Clause{c}
+}
+
+// junction joins cs with and, or with or. A single clause is returned as is.
+func junction(cs []parser.Clause, and bool) parser.Clause {
+	if len(cs) == 1 {
+		return cs[0]
+	}
+
+	s := span(cs[0])
+	for _, c := range cs[1:] {
+		s = cover(s, span(c))
+	}
+	if and {
+		return &parser.AndClause{Span: s, Clauses: cs}
+	}
+	return &parser.OrClause{Span: s, Clauses: cs}
+}
+
+func span(n parser.Node) parser.Span {
+	return parser.Span{Start: n.Pos(), Stop: n.End()}
+}
+
+// cover returns the smallest span including a and b.
+func cover(a, b parser.Span) parser.Span {
+	return parser.Span{Start: min(a.Start, b.Start), Stop: max(a.Stop, b.Stop)}
+}
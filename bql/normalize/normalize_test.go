@@ -0,0 +1,277 @@
+package normalize_test
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/format"
+	"launchpad.net/kjvonly-bql/bql/normalize"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+func normalized(t *testing.T, input string, form normalize.Form) string {
+	t.Helper()
+	q, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("%q: unexpected error: %s", input, err)
+	}
+	s := format.Node(normalize.Query(q, normalize.Options{Form: form}))
+	if _, err := parser.Parse(s); err != nil {
+		t.Fatalf("%q: normalized to %q which does not validate: %s", input, s, err)
+	}
+	return s
+}
+
+func TestNormalize(t *testing.T) {
+	inputs := map[string]string{
+		`book = john or book = john and text = love`:                     `book = "john"`,
+		`book = john and (book = john or text = love)`:                   `book = "john"`,
+		`(book = john and text = love) or (text = love and book = john)`: `book = "john" and text = "love"`,
+		`book = john and (text = love and (verse = 1 and book = john))`:  `book = "john" and text = "love" and verse = 1`,
+		`not not book = john`:                                                     `book = "john"`,
+		`not (book = john and text ~ love)`:                                       `not book = "john" or not text ~ "love"`,
+		`not (book = john or not text ~ love)`:                                    `not book = "john" and text ~ "love"`,
+		`book = john or text = love or book = mark`:                               `book in ("john", "mark") or text = "love"`,
+		`book = john or book in (mark, john) or book = luke`:                      `book in ("john", "mark", "luke")`,
+		`book != john and chapter > 1 and book not in (mark)`:                     `book not in ("john", "mark") and chapter > 1`,
+		`book = john and book = mark`:                                             `book = "john" and book = "mark"`,
+		`not (text near(faith, works, 5) or text near(faith, works, 5, ordered))`: `not text near("faith", "works", 5) and not text near("faith", "works", 5, ordered)`,
+		`text near(faith, works, 5) or text near(faith, works, 5, verse)`:         `text near("faith", "works", 5)`,
+		`book = john or chapter = last() or book = mark`:                          `book in ("john", "mark") or chapter = last()`,
+		`count(not (book = john or book = john))`:                                 `count(not book = "john")`,
+		`book = john order by chapter limit 5`:                                    `book = "john" order by chapter limit 5`,
+		`text = love or text = hope`:                                              `text = "love" or text = "hope"`,
+		`t = love or text = hope or t = love`:                                     `text = "love" or text = "hope"`,
+		`text != love and text != hope and book != john and b != mark`:            `text != "love" and text != "hope" and book not in ("john", "mark")`,
+	}
+
+	for input, expected := range inputs {
+		if s := normalized(t, input, normalize.NNF); s != expected {
+			t.Fatalf("%q: expected\n%s\nbut got\n%s", input, expected, s)
+		}
+	}
+}
+
+func TestNormalizeForms(t *testing.T) {
+	inputs := map[string][2]string{
+		`book = john and (text ~ love or text ~ hope)`: {
+			`book = "john" and text ~ "love" or book = "john" and text ~ "hope"`,
+			`book = "john" and (text ~ "love" or text ~ "hope")`,
+		},
+		`(book = john and text ~ love) or verse = 1`: {
+			`book = "john" and text ~ "love" or verse = 1`,
+			`(book = "john" or verse = 1) and (text ~ "love" or verse = 1)`,
+		},
+		`not (book = john or text ~ love) or chapter = 1`: {
+			`not book = "john" and not text ~ "love" or chapter = 1`,
+			`(not book = "john" or chapter = 1) and (not text ~ "love" or chapter = 1)`,
+		},
+		`(book = john or book = mark) and (book = john or text ~ love)`: {
+			`book = "john" or book = "mark" and text ~ "love"`,
+			`book in ("john", "mark") and (book = "john" or text ~ "love")`,
+		},
+	}
+
+	for input, expected := range inputs {
+		if s := normalized(t, input, normalize.DNF); s != expected[0] {
+			t.Fatalf("%q: expected DNF\n%s\nbut got\n%s", input, expected[0], s)
+		}
+		if s := normalized(t, input, normalize.CNF); s != expected[1] {
+			t.Fatalf("%q: expected CNF\n%s\nbut got\n%s", input, expected[1], s)
+		}
+	}
+}
+
+func TestNormalizeDoesNotModifyInput(t *testing.T) {
+	q, err := parser.Parse(`not (book = john or book = mark) and (text ~ love or text ~ love)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	before := format.Node(q)
+	normalize.Query(q, normalize.Options{Form: normalize.DNF})
+	if after := format.Node(q); after != before {
+		t.Fatalf("input modified from %s to %s", before, after)
+	}
+}
+
+// verse is a verse of the test corpus.
+type verse struct {
+	book    string
+	chapter int64
+	text    string
+}
+
+var (
+	books    = []string{"john", "mark", "luke"}
+	words    = []string{"love", "faith", "hope"}
+	chapters = []int64{1, 2, 3}
+)
+
+// corpus returns every combination of book, chapter and word.
+func corpus() []verse {
+	var vs []verse
+	for _, b := range books {
+		for _, c := range chapters {
+			for _, w := range words {
+				vs = append(vs, verse{b, c, "in " + w})
+			}
+		}
+	}
+	return vs
+}
+
+// eval is a minimal evaluator of the clauses generated by randomClause.
+func eval(t *testing.T, c parser.Clause, v verse) bool {
+	switch c := c.(type) {
+	case *parser.OrClause:
+		for _, cc := range c.Clauses {
+			if eval(t, cc, v) {
+				return true
+			}
+		}
+		return false
+	case *parser.AndClause:
+		for _, cc := range c.Clauses {
+			if !eval(t, cc, v) {
+				return false
+			}
+		}
+		return true
+	case *parser.NotClause:
+		return !eval(t, c.Clause, v)
+	case *parser.Comparison:
+		switch c.Operator {
+		case state.IN, state.NOT_IN:
+			in := false
+			for _, o := range c.Value.(*parser.List).Values {
+				in = in || compare(t, c.Field.Name, state.EQ, o, v)
+			}
+			return in == (c.Operator == state.IN)
+		case state.NEQ:
+			return !compare(t, c.Field.Name, state.EQ, c.Value, v)
+		}
+		return compare(t, c.Field.Name, c.Operator, c.Value, v)
+	}
+	t.Fatalf("unexpected clause %T", c)
+	return false
+}
+
+func compare(t *testing.T, field string, op state.ElementType, o parser.Operand, v verse) bool {
+	switch field {
+	case "book":
+		s := o.(*parser.StringLiteral).Value
+		return op == state.EQ && v.book == s
+	case "chapter":
+		n := o.(*parser.NumberLiteral).Int
+		switch op {
+		case state.EQ:
+			return v.chapter == n
+		case state.LT:
+			return v.chapter < n
+		}
+	case "text":
+		return op == state.CONTAINS && strings.Contains(v.text, o.(*parser.StringLiteral).Value)
+	}
+	t.Fatalf("unexpected comparison %s %s", field, op)
+	return false
+}
+
+// randomClause returns the text of a random clause of the given depth.
+func randomClause(r *rand.Rand, depth int) string {
+	if depth == 0 || r.Intn(4) == 0 {
+		pick := func(s []string) string { return s[r.Intn(len(s))] }
+		switch r.Intn(7) {
+		case 0:
+			return "book = " + pick(books)
+		case 1:
+			return "book != " + pick(books)
+		case 2:
+			return fmt.Sprintf("book in (%s, %s)", pick(books), pick(books))
+		case 3:
+			return fmt.Sprintf("book not in (%s)", pick(books))
+		case 4:
+			return fmt.Sprintf("chapter = %d", chapters[r.Intn(len(chapters))])
+		case 5:
+			return fmt.Sprintf("chapter < %d", chapters[r.Intn(len(chapters))])
+		}
+		return "text ~ " + pick(words)
+	}
+
+	switch r.Intn(3) {
+	case 0:
+		return "not (" + randomClause(r, depth-1) + ")"
+	case 1:
+		return "(" + randomClause(r, depth-1) + ") and (" + randomClause(r, depth-1) + ")"
+	}
+	return "(" + randomClause(r, depth-1) + ") or (" + randomClause(r, depth-1) + ") or (" + randomClause(r, depth-1) + ")"
+}
+
+// checkForm fails if c is not in the given form, or is not simplified.
+func checkForm(t *testing.T, input string, c parser.Clause, form normalize.Form) {
+	var kind func(c parser.Clause) string
+	kind = func(c parser.Clause) string {
+		switch c := c.(type) {
+		case *parser.OrClause:
+			return "or"
+		case *parser.AndClause:
+			return "and"
+		case *parser.NotClause:
+			if k := kind(c.Clause); k != "atom" {
+				t.Fatalf("%q: negation of %s in normalized clause", input, k)
+			}
+		}
+		return "atom"
+	}
+
+	var check func(c parser.Clause, parent string, depth int)
+	check = func(c parser.Clause, parent string, depth int) {
+		k := kind(c)
+		if k == parent {
+			t.Fatalf("%q: %s nested in %s", input, k, parent)
+		}
+		if form == normalize.DNF && (k == "or" && depth > 0 || k == "and" && depth > 1) ||
+			form == normalize.CNF && (k == "and" && depth > 0 || k == "or" && depth > 1) {
+			t.Fatalf("%q: %s at depth %d in %s", input, k, depth, format.Node(c))
+		}
+		for i, cc := range parser.Children(c) {
+			for _, o := range parser.Children(c)[:i] {
+				if parser.Equal(o, cc) && k != "atom" {
+					t.Fatalf("%q: duplicate clause %s", input, format.Node(cc))
+				}
+			}
+			if cc, ok := cc.(parser.Clause); ok && k != "atom" {
+				check(cc, k, depth+1)
+			}
+		}
+	}
+	check(c, "", 0)
+}
+
+// TestNormalizeKeepsMeaning checks on random queries that the normalized
+// clauses match the same verses as the original ones.
+func TestNormalizeKeepsMeaning(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	vs := corpus()
+
+	for i := 0; i < 2000; i++ {
+		input := randomClause(r, 4)
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		for _, form := range []normalize.Form{normalize.NNF, normalize.DNF, normalize.CNF} {
+			c := normalize.Clause(q.Clause, normalize.Options{Form: form})
+			checkForm(t, input, c, form)
+
+			for _, v := range vs {
+				if eval(t, q.Clause, v) != eval(t, c, v) {
+					t.Fatalf("%q: normalized (form %d) to %s which does not match %+v the same way", input, form, format.Node(c), v)
+				}
+			}
+		}
+	}
+}
@@ -1,3 +1,195 @@
 package parser
 
-type ASTNode struct{}
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// Span is the byte range of a node in the query text.
+type Span struct {
+	Start int // offset of the first byte of the node
+	Stop  int // offset just past the last byte of the node
+}
+
+func (s Span) Pos() int { return s.Start }
+func (s Span) End() int { return s.Stop }
+
+// Node is implemented by every node of the BQL syntax tree.
+type Node interface {
+	Pos() int
+	End() int
+}
+
+// Clause is implemented by the nodes that evaluate to true or false for a
+// verse.
+type Clause interface {
+	Node
+	clauseNode()
+}
+
+// Operand is implemented by the nodes that can appear on the right hand side
+// of a comparison.
+type Operand interface {
+	Node
+	operandNode()
+}
+
+// Query is the root of a parsed query. Limit and Offset are nil when the
+// query has no limit or offset clause.
+type Query struct {
+	Span
+	Clause  Clause
+	OrderBy []*SortKey
+	Limit   *NumberLiteral
+	Offset  *NumberLiteral
+}
+
+// MaxResults returns the maximum number of results requested by the limit
+// clause. ok is false if the query has no limit.
+func (q *Query) MaxResults() (n int64, ok bool) {
+	if q.Limit == nil {
+		return 0, false
+	}
+	return q.Limit.Int, true
+}
+
+// Skip returns the number of results to skip as requested by the offset
+// clause.
+func (q *Query) Skip() int64 {
+	if q.Offset == nil {
+		return 0
+	}
+	return q.Offset.Int
+}
+
+// Selection returns the clause selecting the verses of q, unwrapping a
+// count(...) call. count is true if q asks for the number of matching verses
+// rather than the verses. Selection fails if the count call does not have a
+// single clause argument, which Parse never produces.
+func (q *Query) Selection() (c Clause, count bool, err error) {
+	f, ok := q.Clause.(*FunctionCall)
+	if !ok || !strings.EqualFold(f.Name, "count") {
+		return q.Clause, false, nil
+	}
+	if len(f.Args) == 1 {
+		if c, ok := f.Args[0].(Clause); ok {
+			return c, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("parser: %d: count() takes a single clause argument", f.Pos())
+}
+
+// SortKey is a key of an order by clause. Books sort in canonical Bible order
+// rather than alphabetically.
+type SortKey struct {
+	Span
+	Field      *Field
+	Descending bool
+}
+
+// OrClause matches if any of its clauses matches.
+type OrClause struct {
+	Span
+	Clauses []Clause
+}
+
+// AndClause matches if all of its clauses match.
+type AndClause struct {
+	Span
+	Clauses []Clause
+}
+
+// NotClause matches if its clause does not match.
+type NotClause struct {
+	Span
+	Clause Clause
+}
+
+// Comparison compares the value of a field with an operand, e.g. book = john.
+type Comparison struct {
+	Span
+	Field    *Field
+	Operator state.ElementType
+	Value    Operand
+}
+
+// Field names a KJVonly field, e.g. book or text.
+type Field struct {
+	Span
+	Name string
+}
+
+// StringLiteral is a quoted or unquoted string. Value holds the unquoted text.
+type StringLiteral struct {
+	Span
+	Value string
+}
+
+// RefLiteral is a scripture reference such as "John 3:16-18", the value of a
+// reference field. The parser reads it as a string; validation resolves it.
+// Value holds the reference as written and Verses the sorted set of the verses
+// it references.
+type RefLiteral struct {
+	Span
+	Value  string
+	Verses []bible.VerseID
+}
+
+// NumberLiteral is an integer or floating point literal.
+type NumberLiteral struct {
+	Span
+	IsFloat bool
+	Int     int64   // value of an integer literal
+	Float   float64 // value of a floating point literal
+}
+
+// Float64 returns the value of the literal as a float64.
+func (n *NumberLiteral) Float64() float64 {
+	if n.IsFloat {
+		return n.Float
+	}
+	return float64(n.Int)
+}
+
+// FunctionCall is a call such as count(book = john). Arguments are either
+// clauses or operands.
+type FunctionCall struct {
+	Span
+	Name string
+	Args []Node
+}
+
+// List is a parenthesized list of operands, e.g. (john, mark).
+type List struct {
+	Span
+	Values []Operand
+}
+
+// Proximity is the operand of the near operator, e.g. text near("faith",
+// "works", 5): two texts at most Distance terms apart. The texts may come in
+// either order unless Ordered is set, and must be in the same verse unless
+// AcrossVerses is set, in which case they may be in adjacent verses.
+type Proximity struct {
+	Span
+	First        *StringLiteral
+	Second       *StringLiteral
+	Distance     *NumberLiteral
+	Ordered      bool
+	AcrossVerses bool
+}
+
+func (*OrClause) clauseNode()     {}
+func (*AndClause) clauseNode()    {}
+func (*NotClause) clauseNode()    {}
+func (*Comparison) clauseNode()   {}
+func (*FunctionCall) clauseNode() {}
+
+func (*StringLiteral) operandNode() {}
+func (*RefLiteral) operandNode()    {}
+func (*NumberLiteral) operandNode() {}
+func (*FunctionCall) operandNode()  {}
+func (*List) operandNode()          {}
+func (*Proximity) operandNode()     {}
@@ -1 +1,69 @@
 package parser_test
+
+import (
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+func TestNodeSpans(t *testing.T) {
+	//                   0123456789012345678901234
+	q, err := parser.Parse(`book = "john" and text=x`)
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	ac := q.Clause.(*parser.AndClause)
+	c1 := ac.Clauses[0].(*parser.Comparison)
+	c2 := ac.Clauses[1].(*parser.Comparison)
+
+	spans := []struct {
+		name     string
+		n        parser.Node
+		pos, end int
+	}{
+		{"query", q, 0, 24},
+		{"and", ac, 0, 24},
+		{"comparison", c1, 0, 13},
+		{"field", c1.Field, 0, 4},
+		{"quoted string", c1.Value, 7, 13},
+		{"comparison without spaces", c2, 18, 24},
+		{"unquoted string", c2.Value, 23, 24},
+	}
+
+	for _, s := range spans {
+		if s.n.Pos() != s.pos || s.n.End() != s.end {
+			t.Fatalf("%s: expected span %d-%d but got %d-%d", s.name, s.pos, s.end, s.n.Pos(), s.n.End())
+		}
+	}
+
+	if v := c1.Value.(*parser.StringLiteral).Value; v != "john" {
+		t.Fatalf("expected unquoted value john but got %q", v)
+	}
+}
+
+func TestQuerySelection(t *testing.T) {
+	q, err := parser.Parse(`count(book = john)`)
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+	c, count, err := q.Selection()
+	if err != nil || !count {
+		t.Fatalf("expected a count query but got %v, %v", count, err)
+	}
+	if _, ok := c.(*parser.Comparison); !ok {
+		t.Fatalf("expected the comparison of the count call but got %T", c)
+	}
+
+	if q, err = parser.Parse(`book = john`); err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+	if c, count, err := q.Selection(); err != nil || count || c != q.Clause {
+		t.Fatalf("expected the clause of the query but got %T, %v, %v", c, count, err)
+	}
+
+	q = &parser.Query{Clause: &parser.FunctionCall{Name: "count", Args: []parser.Node{&parser.StringLiteral{Value: "x"}}}}
+	if _, _, err := q.Selection(); err == nil {
+		t.Fatalf("expected an error for a count call without a clause")
+	}
+}
@@ -1,54 +1,124 @@
 package parser
 
 import (
+	"fmt"
+
 	"launchpad.net/kjvonly-bql/bql/state"
 	"launchpad.net/kjvonly-bql/lex"
 )
 
 type Builder struct {
-	Expression          *Expression
-	Lexer               *lex.Lexer
-	CurrentToken        Token
-	OrphanedExpressions []*Expression
+	Lexer        *lex.Lexer
+	CurrentToken Token
+	PreviousEnd  int // end offset of the last consumed token
+	Diagnostics  Diagnostics
+	next         *Token
 }
 
 func NewBuilder(lex *lex.Lexer) *Builder {
 	return &Builder{
-		Lexer:      lex,
-		Expression: &Expression{},
+		Lexer: lex,
 	}
 }
 
-func (b *Builder) AddExpression() *Expression {
-	e := &Expression{}
-	b.OrphanedExpressions = append(b.OrphanedExpressions, e)
-	return e
+func (b *Builder) GetTokenType() state.ElementType {
+	return b.CurrentToken.Type
 }
 
-func (b *Builder) AssignOrphanedExpressions(e *Expression) {
-	e.Expressions = append(e.Expressions, b.OrphanedExpressions...)
-	b.OrphanedExpressions = b.OrphanedExpressions[:0]
-	if e.IsDone {
-		b.OrphanedExpressions = append(b.OrphanedExpressions, e)
+// AdvanceLexer reads the next token into CurrentToken. Error tokens emitted by
+// the lexer are recorded as diagnostics and skipped.
+func (b *Builder) AdvanceLexer() {
+	if b.CurrentToken.Type != "" {
+		b.PreviousEnd = b.CurrentToken.End
+	}
+
+	if b.next != nil {
+		b.CurrentToken = *b.next
+		b.next = nil
+		return
 	}
+	b.CurrentToken = b.lex()
 }
 
-func (b *Builder) GetTokenType() state.ElementType {
-	return b.CurrentToken.Type
+// PeekTokenType returns the type of the token following the current one
+// without consuming it.
+func (b *Builder) PeekTokenType() state.ElementType {
+	if b.next == nil {
+		t := b.lex()
+		b.next = &t
+	}
+	return b.next.Type
 }
 
-func (b *Builder) AdvanceLexer() {
-	t, _, v := b.Lexer.Lex()
+func (b *Builder) lex() Token {
+	for {
+		t, p, v := b.Lexer.Lex()
+
+		ty, ok := state.TokenTypes[t]
+		if !ok {
+			panic("wrong lek.Token")
+		}
 
-	ty, ok := state.TokenTypes[t]
-	if !ok {
-		panic("wrong lek.Token")
+		if t != lex.Error {
+			return Token{ty, v, p, b.Lexer.Offset()}
+		}
+		b.add(Diagnostic{
+			Message:  v.(error).Error(),
+			Severity: SeverityError,
+			Offset:   p,
+			End:      b.Lexer.Offset(),
+		})
 	}
+}
+
+// Span returns the span from start to the end of the last consumed token.
+func (b *Builder) Span(start int) Span {
+	return Span{start, b.PreviousEnd}
+}
 
-	b.CurrentToken = Token{ty, v}
+// Error records an error diagnostic at the current token. expected lists the
+// token sets that would have been accepted at this point.
+func (b *Builder) Error(err string, expected ...map[state.ElementType]bool) {
+	b.addDiagnostic(SeverityError, err, expected)
+}
+
+// ErrorAt records an error diagnostic spanning the node n.
+func (b *Builder) ErrorAt(n Node, err string) {
+	b.add(Diagnostic{
+		Message:  err,
+		Severity: SeverityError,
+		Offset:   n.Pos(),
+		End:      n.End(),
+	})
+}
 
+// Warning records a warning diagnostic at the current token.
+func (b *Builder) Warning(msg string) {
+	b.addDiagnostic(SeverityWarning, msg, nil)
 }
 
-func (b *Builder) Error(err string) {
-	// TODO implement
+func (b *Builder) addDiagnostic(s Severity, msg string, expected []map[state.ElementType]bool) {
+	ct := b.CurrentToken
+	d := Diagnostic{
+		Message:  msg,
+		Severity: s,
+		Offset:   ct.Pos,
+		End:      ct.End,
+		Expected: expectedSet(expected),
+	}
+	switch ct.Type {
+	case "", state.ERROR:
+	case state.EOF:
+		d.Message = msg + ", found end of query"
+	default:
+		d.Message = fmt.Sprintf("%s, found %s", msg, ct)
+	}
+	b.add(d)
+}
+
+func (b *Builder) add(d Diagnostic) {
+	if b.Lexer != nil {
+		d.Position = b.Lexer.File().Position(d.Offset)
+	}
+	b.Diagnostics = append(b.Diagnostics, d)
 }
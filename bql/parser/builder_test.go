@@ -7,19 +7,6 @@ import (
 	"launchpad.net/kjvonly-bql/bql/state"
 )
 
-func TestBuilderAddExpression(t *testing.T) {
-	b := parser.NewBuilder(nil)
-	e := b.AddExpression()
-
-	if e.Expressions != nil {
-		t.Fatalf("Should have nil Next")
-	}
-
-	if b.Expression == nil {
-		t.Fatalf("Should have non nil Builder.Marker")
-	}
-}
-
 func TestBuilderGetTokenType(t *testing.T) {
 	l := state.BQLLexer("book = john")
 	b := parser.NewBuilder(l)
@@ -57,20 +44,53 @@ func TestBuilderAdvanceLexer(t *testing.T) {
 func TestBuilderError(t *testing.T) {
 	b := &parser.Builder{}
 
-	b.Error("expecting field name")
+	b.Error("expecting field name", state.VALID_FIELD_NAMES)
 
-	// TODO finish test
+	if len(b.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic but got %d", len(b.Diagnostics))
+	}
+
+	d := b.Diagnostics[0]
+	if d.Message != "expecting field name" {
+		t.Fatalf("unexpected message %q", d.Message)
+	}
+
+	if len(d.Expected) != len(state.VALID_FIELD_NAMES) {
+		t.Fatalf("expected %d expected token types but got %v", len(state.VALID_FIELD_NAMES), d.Expected)
+	}
+
+	if !b.Diagnostics.HasErrors() {
+		t.Fatalf("expected diagnostics to have errors")
+	}
 }
 
-func TestBuilderAssignOrphanedExpressions(t *testing.T) {
-	b := parser.NewBuilder(state.BQLLexer("="))
-	b.AddExpression()
+func TestBuilderErrorPosition(t *testing.T) {
+	b := parser.NewBuilder(state.BQLLexer("book\n  = john"))
+	// book, semicolon, =
+	b.AdvanceLexer()
+	b.AdvanceLexer()
+	b.AdvanceLexer()
+
+	b.Error("unexpected operator")
+
+	d := b.Diagnostics[0]
+	if d.Offset != 7 || d.End != 8 {
+		t.Fatalf("expected offsets 7-8 but got %d-%d", d.Offset, d.End)
+	}
 
-	b.OrphanedExpressions = append(b.OrphanedExpressions, []*parser.Expression{{}, {}}...)
-	e := &parser.Expression{}
-	b.AssignOrphanedExpressions(e)
+	if d.Position.Line != 2 || d.Position.Column != 3 {
+		t.Fatalf("expected position 2:3 but got %d:%d", d.Position.Line, d.Position.Column)
+	}
+}
+
+func TestBuilderSpan(t *testing.T) {
+	b := parser.NewBuilder(state.BQLLexer("book = john"))
+	b.AdvanceLexer()
+	b.AdvanceLexer()
+	b.AdvanceLexer()
 
-	if len(b.OrphanedExpressions) != 0 {
-		t.Fatalf("expected OrphanedExpressions to be 0")
+	sp := b.Span(0)
+	if sp.Pos() != 0 || sp.End() != 6 {
+		t.Fatalf("expected span 0-6 but got %d-%d", sp.Pos(), sp.End())
 	}
 }
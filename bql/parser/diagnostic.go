@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/state"
+	"launchpad.net/kjvonly-bql/lex"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic describes a problem found in a query. Offset and End are the byte
+// offsets of the offending token so that callers can underline it.
+type Diagnostic struct {
+	Message  string
+	Severity Severity
+	Offset   int
+	End      int
+	Position lex.Position
+	Expected []state.ElementType
+}
+
+func (d Diagnostic) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%d:%d: %s: %s", d.Position.Line, d.Position.Column, d.Severity, d.Message)
+	if len(d.Expected) > 0 {
+		es := make([]string, len(d.Expected))
+		for i, e := range d.Expected {
+			es[i] = string(e)
+		}
+		fmt.Fprintf(&sb, " (expected one of %s)", strings.Join(es, ", "))
+	}
+	return sb.String()
+}
+
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any of the diagnostics has SeverityError.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Error implements the error interface by listing every diagnostic, one per
+// line.
+func (ds Diagnostics) Error() string {
+	ls := make([]string, len(ds))
+	for i, d := range ds {
+		ls[i] = d.String()
+	}
+	return strings.Join(ls, "\n")
+}
+
+// expectedSet merges token sets into a sorted slice of element types.
+func expectedSet(sets []map[state.ElementType]bool) []state.ElementType {
+	m := map[state.ElementType]bool{}
+	for _, s := range sets {
+		for t := range s {
+			m[t] = true
+		}
+	}
+	if len(m) == 0 {
+		return nil
+	}
+
+	es := make([]state.ElementType, 0, len(m))
+	for t := range m {
+		es = append(es, t)
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i] < es[j] })
+	return es
+}
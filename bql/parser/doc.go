@@ -10,7 +10,7 @@ package parser
  * Slightly refactored JQL grammar. See original ANTLR parser grammar at:
  * http://jira.stagingonserver.com/jira-project/jira-components/jira-core/src/main/antlr3/com/atlassian/jira/jql/parser/antlr/Jql.g
  *
- * query ::= or_clause [order_by]
+ * query ::= or_clause [order_by] [limit] [offset]
  * or_clause ::= and_clause {or_op and_clause}
  * and_clause ::= not_expr {and_op not_expr}
  * not_expr ::= not_op not_expr
@@ -21,6 +21,8 @@ package parser
  *                   | was_clause
  *                   | changed_clause
  * simple_clause ::= field simple_op value
+ *                 | field "near" "(" string "," string "," INTEGER {"," near_option} ")"
+ * near_option ::= "ordered" | "unordered" | "verse" | "adjacent"
  * # although this is not mentioned in JQL manual, usage of both "from" and "to" predicates in "was" clause is legal
  * was_clause ::= field "was" ["not"] ["in"] operand {history_predicate}
  * changed_clause ::= field "changed" {history_predicate}
@@ -62,8 +64,11 @@ package parser
  * string ::= SQUOTED_STRING
  *          | QUOTED_STRING
  *          | UNQOUTED_STRING
- * order_by ::= "order" "by" sort_key {sort_key}
- * sort_key ::= field ("asc" | "desc")
+ * # compared to a reference field, a string is a scripture reference, e.g. "John 3:16-18"
+ * order_by ::= "order" "by" sort_key {"," sort_key}
+ * sort_key ::= field ["asc" | "desc"]
+ * limit ::= "limit" INTEGER
+ * offset ::= "offset" INTEGER
  *
  */
 
@@ -0,0 +1,110 @@
+package parser
+
+// Equal reports whether the trees rooted at a and b are structurally equal,
+// i.e. whether they only differ by their spans.
+func Equal(a, b Node) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	switch a := a.(type) {
+	case *Query:
+		b, ok := b.(*Query)
+		if !ok || !Equal(a.Clause, b.Clause) || len(a.OrderBy) != len(b.OrderBy) {
+			return false
+		}
+		for i := range a.OrderBy {
+			if !Equal(a.OrderBy[i], b.OrderBy[i]) {
+				return false
+			}
+		}
+		return equalNumber(a.Limit, b.Limit) && equalNumber(a.Offset, b.Offset)
+	case *SortKey:
+		b, ok := b.(*SortKey)
+		return ok && a.Descending == b.Descending && equalField(a.Field, b.Field)
+	case *OrClause:
+		b, ok := b.(*OrClause)
+		return ok && equalClauses(a.Clauses, b.Clauses)
+	case *AndClause:
+		b, ok := b.(*AndClause)
+		return ok && equalClauses(a.Clauses, b.Clauses)
+	case *NotClause:
+		b, ok := b.(*NotClause)
+		return ok && Equal(a.Clause, b.Clause)
+	case *Comparison:
+		b, ok := b.(*Comparison)
+		return ok && a.Operator == b.Operator && equalField(a.Field, b.Field) && Equal(a.Value, b.Value)
+	case *Field:
+		b, ok := b.(*Field)
+		return ok && equalField(a, b)
+	case *StringLiteral:
+		b, ok := b.(*StringLiteral)
+		return ok && a.Value == b.Value
+	case *RefLiteral:
+		b, ok := b.(*RefLiteral)
+		return ok && a.Value == b.Value
+	case *NumberLiteral:
+		b, ok := b.(*NumberLiteral)
+		return ok && equalNumber(a, b)
+	case *FunctionCall:
+		b, ok := b.(*FunctionCall)
+		if !ok || a.Name != b.Name || len(a.Args) != len(b.Args) {
+			return false
+		}
+		for i := range a.Args {
+			if !Equal(a.Args[i], b.Args[i]) {
+				return false
+			}
+		}
+		return true
+	case *Proximity:
+		b, ok := b.(*Proximity)
+		return ok && a.Ordered == b.Ordered && a.AcrossVerses == b.AcrossVerses &&
+			equalString(a.First, b.First) && equalString(a.Second, b.Second) && equalNumber(a.Distance, b.Distance)
+	case *List:
+		b, ok := b.(*List)
+		if !ok || len(a.Values) != len(b.Values) {
+			return false
+		}
+		for i := range a.Values {
+			if !Equal(a.Values[i], b.Values[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func equalClauses(a, b []Clause) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalField(a, b *Field) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Name == b.Name
+}
+
+func equalString(a, b *StringLiteral) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Value == b.Value
+}
+
+func equalNumber(a, b *NumberLiteral) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.IsFloat == b.IsFloat && a.Int == b.Int && a.Float == b.Float
+}
@@ -0,0 +1,41 @@
+package parser_test
+
+import (
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+func TestEqual(t *testing.T) {
+	inputs := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"book = john", `book   =   "john"`, true},
+		{"(book = john) and verse = 1", "book = john and (verse = 1)", true},
+		{"not book = john", "!book = john", true},
+		{"book = john order by book limit 1", "book = john order by book limit 1", true},
+		{"book = john", "book = mark", false},
+		{"book = john", "book != john", false},
+		{"verse = 1", "verse = 1.0", false},
+		{"book = john or (verse = 1 or verse = 2)", "book = john or verse = 1 or verse = 2", false},
+		{"book in (john, mark)", "book in (mark, john)", false},
+		{"book = john order by book", "book = john order by book desc", false},
+		{"book = john limit 1", "book = john offset 1", false},
+	}
+
+	for _, in := range inputs {
+		a, err := parser.Parse(in.a)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", in.a, err)
+		}
+		b, err := parser.Parse(in.b)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", in.b, err)
+		}
+
+		if parser.Equal(a, b) != in.equal {
+			t.Fatalf("expected Equal(%q, %q) to be %v", in.a, in.b, in.equal)
+		}
+	}
+}
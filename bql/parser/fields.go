@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// ValueType is the type of the values of a field.
+type ValueType int
+
+const (
+	EnumValue ValueType = iota // one of a fixed set of names, e.g. books
+	IntValue                   // an integer
+	TextValue                  // free text
+	RefValue                   // a verse reference
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case EnumValue:
+		return "enum"
+	case IntValue:
+		return "int"
+	case TextValue:
+		return "text"
+	case RefValue:
+		return "ref"
+	}
+	return fmt.Sprintf("ValueType(%d)", int(t))
+}
+
+// FieldDef describes a field that queries can test. Aliases are alternative
+// names of the field; Operators lists the operators the field accepts.
+type FieldDef struct {
+	Name      string
+	Aliases   []string
+	Type      ValueType
+	Operators []state.ElementType
+	// Resolve, if set, returns the canonical form of a value of the field,
+	// e.g. the name of a book given its abbreviation, and reports whether
+	// the value is valid.
+	Resolve func(value string) (string, bool)
+}
+
+// Allows reports whether op can be used with the field.
+func (f *FieldDef) Allows(op state.ElementType) bool {
+	for _, o := range f.Operators {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	fieldDefs  = map[string]*FieldDef{} // by name and alias
+	fieldNames []string                 // names and aliases, in registration order
+)
+
+// RegisterField makes a field available to queries. Names and aliases are
+// case insensitive. RegisterField panics if the name or an alias of the field
+// is already taken. The returned function unregisters the field, e.g. at the
+// end of a test.
+func RegisterField(f FieldDef) (unregister func()) {
+	names := append([]string{f.Name}, f.Aliases...)
+	for _, name := range names {
+		if _, ok := fieldDefs[strings.ToLower(name)]; ok {
+			panic("parser: RegisterField called twice for field " + name)
+		}
+	}
+	for _, name := range names {
+		fieldDefs[strings.ToLower(name)] = &f
+		fieldNames = append(fieldNames, name)
+	}
+
+	return func() {
+		for _, name := range names {
+			if fieldDefs[strings.ToLower(name)] != &f {
+				continue // already unregistered
+			}
+			delete(fieldDefs, strings.ToLower(name))
+			if i := slices.Index(fieldNames, name); i >= 0 {
+				fieldNames = slices.Delete(fieldNames, i, i+1)
+			}
+		}
+	}
+}
+
+// LookupField returns the registered field with the given name or alias.
+func LookupField(name string) (*FieldDef, bool) {
+	f, ok := fieldDefs[strings.ToLower(name)]
+	return f, ok
+}
+
+// FieldType returns the value type of the named field. ok is false if the
+// field is not registered.
+func FieldType(name string) (t ValueType, ok bool) {
+	f, ok := LookupField(name)
+	if !ok {
+		return 0, false
+	}
+	return f.Type, true
+}
+
+// LiteralValue returns the value of a literal compared to the named field:
+// the canonical form of a string, see CanonicalValue, or the int64 or float64
+// value of a number. Integers compared to a field that resolves its values
+// are resolved too, e.g. book = 40 compares with Matthew. ok is false if o is
+// not a string or number literal.
+func LiteralValue(field string, o Operand) (v any, ok bool) {
+	switch o := o.(type) {
+	case *StringLiteral:
+		return CanonicalValue(field, o.Value), true
+	case *NumberLiteral:
+		if o.IsFloat {
+			return o.Float, true
+		}
+		if f, ok := LookupField(field); ok && f.Resolve != nil {
+			if v, ok := f.Resolve(strconv.FormatInt(o.Int, 10)); ok {
+				return v, true
+			}
+		}
+		return o.Int, true
+	}
+	return nil, false
+}
+
+// CanonicalValue returns the canonical form of a string value of the named
+// field, see FieldDef.Resolve. It returns the value itself if the field is not
+// registered, does not resolve its values or the value is invalid.
+func CanonicalValue(field, value string) string {
+	if f, ok := LookupField(field); ok && f.Resolve != nil {
+		if v, ok := f.Resolve(value); ok {
+			return v
+		}
+	}
+	return value
+}
+
+// suggestField returns the registered name or alias closest to name, if it is
+// close enough to be a likely typo.
+func suggestField(name string) (string, bool) {
+	name = strings.ToLower(name)
+	best, dist := "", len(name)/2+1
+	for _, n := range fieldNames {
+		if d := editDistance(name, strings.ToLower(n)); d < dist {
+			best, dist = n, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+var (
+	equalityOperators = []state.ElementType{state.EQ, state.NEQ, state.IN, state.NOT_IN}
+	orderedOperators  = []state.ElementType{state.EQ, state.NEQ, state.IN, state.NOT_IN, state.LT, state.GT, state.LTE, state.GTE}
+	textOperators     = []state.ElementType{state.EQ, state.NEQ, state.CONTAINS, state.NOT_CONTAINS, state.NEAR}
+)
+
+func init() {
+	RegisterField(FieldDef{
+		Name:      "book",
+		Aliases:   []string{"b"},
+		Type:      EnumValue,
+		Operators: equalityOperators,
+		Resolve:   bible.Resolve,
+	})
+	RegisterField(FieldDef{
+		Name:      "chapter",
+		Aliases:   []string{"c"},
+		Type:      IntValue,
+		Operators: orderedOperators,
+	})
+	RegisterField(FieldDef{
+		Name:      "verse",
+		Aliases:   []string{"v"},
+		Type:      IntValue,
+		Operators: orderedOperators,
+	})
+	RegisterField(FieldDef{
+		Name:      "ref",
+		Aliases:   []string{"reference"},
+		Type:      RefValue,
+		Operators: equalityOperators,
+	})
+	RegisterField(FieldDef{
+		Name:      "text",
+		Aliases:   []string{"t"},
+		Type:      TextValue,
+		Operators: textOperators,
+	})
+}
@@ -0,0 +1,209 @@
+package parser_test
+
+import (
+	"errors"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+func TestLookupField(t *testing.T) {
+	f, ok := parser.LookupField("C")
+	if !ok {
+		t.Fatalf("expected c to be registered")
+	}
+
+	if f.Name != "chapter" || f.Type != parser.IntValue || !f.Allows(state.LTE) || f.Allows(state.CONTAINS) {
+		t.Fatalf("unexpected definition for c: %+v", f)
+	}
+
+	if _, ok := parser.LookupField("testament"); ok {
+		t.Fatalf("expected testament not to be registered")
+	}
+}
+
+func TestLiteralValue(t *testing.T) {
+	values := []struct {
+		field string
+		o     parser.Operand
+		v     any
+	}{
+		{"b", &parser.StringLiteral{Value: "jn"}, "John"},
+		{"text", &parser.StringLiteral{Value: "jn"}, "jn"},
+		{"book", &parser.NumberLiteral{Int: 41}, "Mark"},
+		{"chapter", &parser.NumberLiteral{Int: 3}, int64(3)},
+		{"chapter", &parser.NumberLiteral{IsFloat: true, Float: 1.5}, 1.5},
+	}
+	for _, c := range values {
+		if v, ok := parser.LiteralValue(c.field, c.o); !ok || v != c.v {
+			t.Fatalf("%s: expected %v but got %v", c.field, c.v, v)
+		}
+	}
+
+	if _, ok := parser.LiteralValue("book", &parser.List{}); ok {
+		t.Fatalf("expected a list not to be a literal")
+	}
+	if ty, ok := parser.FieldType("T"); !ok || ty != parser.TextValue {
+		t.Fatalf("expected t to be a text field but got %v", ty)
+	}
+}
+
+func TestRegisterField(t *testing.T) {
+	t.Cleanup(parser.RegisterField(parser.FieldDef{
+		Name:      "Section",
+		Aliases:   []string{"sec"},
+		Type:      parser.EnumValue,
+		Operators: []state.ElementType{state.EQ},
+	}))
+
+	q, err := parser.Parse(`SEC = law`)
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+	if name := q.Clause.(*parser.Comparison).Field.Name; name != "Section" {
+		t.Fatalf("expected field Section but got %s", name)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("The code did not panic")
+		}
+	}()
+	parser.RegisterField(parser.FieldDef{Name: "other", Aliases: []string{"section"}})
+}
+
+func TestUnregisterField(t *testing.T) {
+	unregister := parser.RegisterField(parser.FieldDef{
+		Name:      "testament",
+		Aliases:   []string{"tm"},
+		Type:      parser.EnumValue,
+		Operators: []state.ElementType{state.EQ},
+	})
+	if _, err := parser.Parse(`tm = old`); err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	unregister()
+	if _, ok := parser.LookupField("tm"); ok {
+		t.Fatalf("expected tm not to be registered")
+	}
+	if _, err := parser.Parse(`testament = old`); err == nil {
+		t.Fatalf("expected an error for the unregistered field")
+	}
+
+	// the names are free again
+	t.Cleanup(parser.RegisterField(parser.FieldDef{Name: "Testament"}))
+}
+
+func TestValidate(t *testing.T) {
+	inputs := map[string]string{
+		`boook = john`:                       "unknown field 'boook', did you mean 'book'?",
+		`book = john and chaptr > 3`:         "unknown field 'chaptr', did you mean 'chapter'?",
+		`text ~ love order by vers`:          "unknown field 'vers', did you mean 'verse'?",
+		`testament = new`:                    "unknown field 'testament'",
+		`book ~ john`:                        "operator '~' not allowed for field 'book'",
+		`text > love`:                        "operator '>' not allowed for field 'text'",
+		`book not in (john, 1.5)`:            "expected a string for field 'book'",
+		`book = 67`:                          "unknown value '67' for field 'book'",
+		`chapter in (1, two)`:                "expected a number for field 'chapter'",
+		`count(book = john and verse = one)`: "expected a number for field 'verse'",
+		`book in (john, jhon)`:               "unknown value 'jhon' for field 'book'",
+		`ref = "John 3:37"`:                  "invalid reference 'John 3:37': John 3 has no verse 37",
+		`ref in ("Gen 1", "Hez 1")`:          "invalid reference 'Hez 1': unknown book 'Hez'",
+		`ref = 3`:                            "expected a string for field 'ref'",
+		`ref ~ "John 3"`:                     "operator '~' not allowed for field 'ref'",
+		`book near(john, mark, 3)`:           "operator 'near' not allowed for field 'book'",
+	}
+
+	for input, msg := range inputs {
+		_, err := parser.Parse(input)
+
+		var diags parser.Diagnostics
+		if !errors.As(err, &diags) {
+			t.Fatalf("%q: expected error to be parser.Diagnostics but got %T", input, err)
+		}
+		if len(diags) != 1 || diags[0].Message != msg {
+			t.Fatalf("%q: expected diagnostic %q but got %s", input, msg, diags)
+		}
+	}
+}
+
+func TestValidateBookNames(t *testing.T) {
+	input := `book in (mat, "1 Cor", "I Corinthians", "40", 41, Canticles, "ＪＯＨＮ")`
+	if _, err := parser.Parse(input); err != nil {
+		t.Fatalf("%q: unexpected error: %s", input, err)
+	}
+
+	if v := parser.CanonicalValue("b", "song of songs"); v != "Song of Solomon" {
+		t.Fatalf("expected Song of Solomon but got %s", v)
+	}
+	if v := parser.CanonicalValue("text", "mat"); v != "mat" {
+		t.Fatalf("expected text values to be left alone but got %s", v)
+	}
+}
+
+func TestValidateReferences(t *testing.T) {
+	q, err := parser.Parse(`ref = "John 3:16-17" and reference not in ("Ps 23", john)`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var refs []*parser.RefLiteral
+	parser.Inspect(q, func(n parser.Node) bool {
+		if r, ok := n.(*parser.RefLiteral); ok {
+			refs = append(refs, r)
+		}
+		return true
+	})
+
+	if len(refs) != 3 {
+		t.Fatalf("expected 3 references but got %d", len(refs))
+	}
+	if r := refs[0]; r.Value != "John 3:16-17" || len(r.Verses) != 2 || r.Verses[0] != 43003016 || r.Pos() != 6 || r.End() != 20 {
+		t.Fatalf("unexpected reference %+v", r)
+	}
+	if len(refs[1].Verses) != 6 || len(refs[2].Verses) != 879 {
+		t.Fatalf("expected 6 and 879 verses but got %d and %d", len(refs[1].Verses), len(refs[2].Verses))
+	}
+}
+
+func TestValidateCanonicalizesAliases(t *testing.T) {
+	q, err := parser.Parse(`B = john and t ~ love order by c, V desc`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var names []string
+	parser.Inspect(q, func(n parser.Node) bool {
+		if f, ok := n.(*parser.Field); ok {
+			names = append(names, f.Name)
+		}
+		return true
+	})
+
+	expected := []string{"book", "text", "chapter", "verse"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected fields %v but got %v", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("expected fields %v but got %v", expected, names)
+		}
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	_, err := parser.Parse(`boook = john or chapter = one or text < 3`)
+
+	var diags parser.Diagnostics
+	if !errors.As(err, &diags) {
+		t.Fatalf("expected error to be parser.Diagnostics but got %T", err)
+	}
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics but got %d: %s", len(diags), diags)
+	}
+	if diags[0].Offset != 0 || diags[1].Offset != 26 {
+		t.Fatalf("unexpected offsets in %s", diags)
+	}
+}
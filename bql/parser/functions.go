@@ -0,0 +1,99 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FunctionKind tells where a call to a function may appear in a query.
+type FunctionKind int
+
+const (
+	// QueryFunction calls wrap a whole query, e.g. count(book = john).
+	QueryFunction FunctionKind = iota
+	// ClauseFunction calls can be used anywhere a clause can.
+	ClauseFunction
+	// OperandFunction calls are used as the value of a comparison, e.g.
+	// chapter = last().
+	OperandFunction
+)
+
+func (k FunctionKind) String() string {
+	switch k {
+	case QueryFunction:
+		return "query"
+	case ClauseFunction:
+		return "clause"
+	case OperandFunction:
+		return "operand"
+	}
+	return fmt.Sprintf("FunctionKind(%d)", int(k))
+}
+
+// ArgKind describes what a function argument may be.
+type ArgKind int
+
+const (
+	ArgClause  ArgKind = iota // an or_clause
+	ArgString                 // a string literal
+	ArgNumber                 // a number literal
+	ArgOperand                // any operand
+)
+
+func (k ArgKind) String() string {
+	switch k {
+	case ArgClause:
+		return "clause"
+	case ArgString:
+		return "string"
+	case ArgNumber:
+		return "number"
+	case ArgOperand:
+		return "operand"
+	}
+	return fmt.Sprintf("ArgKind(%d)", int(k))
+}
+
+// Function describes a BQL function. Args lists the kind of every argument;
+// the arguments past the first MinArgs ones are optional.
+type Function struct {
+	Name    string
+	Kind    FunctionKind
+	Args    []ArgKind
+	MinArgs int
+}
+
+var functions = map[string]*Function{}
+
+// RegisterFunction makes a function available to the parser. Function names
+// are case insensitive. RegisterFunction panics if a function with the same
+// name is already registered.
+func RegisterFunction(f Function) {
+	name := strings.ToLower(f.Name)
+	if _, ok := functions[name]; ok {
+		panic("parser: RegisterFunction called twice for function " + f.Name)
+	}
+	if f.MinArgs > len(f.Args) {
+		panic("parser: function " + f.Name + " requires more arguments than it accepts")
+	}
+	functions[name] = &f
+}
+
+// LookupFunction returns the registered function with the given name.
+func LookupFunction(name string) (*Function, bool) {
+	f, ok := functions[strings.ToLower(name)]
+	return f, ok
+}
+
+func init() {
+	RegisterFunction(Function{
+		Name:    "count",
+		Kind:    QueryFunction,
+		Args:    []ArgKind{ArgClause},
+		MinArgs: 1,
+	})
+	RegisterFunction(Function{
+		Name: "last",
+		Kind: OperandFunction,
+	})
+}
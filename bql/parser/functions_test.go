@@ -0,0 +1,50 @@
+package parser_test
+
+import (
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+func TestLookupFunction(t *testing.T) {
+	f, ok := parser.LookupFunction("COUNT")
+	if !ok {
+		t.Fatalf("expected count to be registered")
+	}
+
+	if f.Kind != parser.QueryFunction || len(f.Args) != 1 || f.Args[0] != parser.ArgClause {
+		t.Fatalf("unexpected definition for count: %+v", f)
+	}
+
+	if _, ok := parser.LookupFunction("nosuchfunction"); ok {
+		t.Fatalf("expected nosuchfunction not to be registered")
+	}
+}
+
+func TestRegisterFunction(t *testing.T) {
+	parser.RegisterFunction(parser.Function{
+		Name:    "Between",
+		Kind:    parser.ClauseFunction,
+		Args:    []parser.ArgKind{parser.ArgString, parser.ArgOperand, parser.ArgOperand},
+		MinArgs: 2,
+	})
+
+	for _, input := range []string{`between("chapter", 3)`, `book = john and between(chapter, 3, 5.5)`} {
+		if _, err := parser.Parse(input); err != nil {
+			t.Fatalf("%q: expected no error but got %s", input, err)
+		}
+	}
+
+	for _, input := range []string{`between("chapter")`, `between("chapter", 1, 2, 3)`, `between(3, 1)`, `between(book = john, a)`} {
+		if _, err := parser.Parse(input); err == nil {
+			t.Fatalf("%q: expected an error", input)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("The code did not panic")
+		}
+	}()
+	parser.RegisterFunction(parser.Function{Name: "between"})
+}
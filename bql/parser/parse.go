@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// Parse parses a BQL query. It takes care of wiring the lexer and builder and
+// of priming the lexer, and validates the fields of a syntactically valid
+// query, see Parser.Validate. If the query is invalid, the returned error is
+// the Diagnostics listing every problem found.
+func Parse(query string) (*Query, error) {
+	b := NewBuilder(state.BQLLexer(query))
+	b.AdvanceLexer()
+
+	p := Parser{}
+	q, diags := p.ParseQuery(b)
+	if !diags.HasErrors() {
+		p.Validate(b, q)
+		diags = b.Diagnostics
+	}
+	if diags.HasErrors() {
+		return nil, diags
+	}
+
+	return q, nil
+}
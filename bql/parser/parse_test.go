@@ -0,0 +1,81 @@
+package parser_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+func TestParse(t *testing.T) {
+	q, err := parser.Parse("book = john and book = mark or book = matthew")
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	if _, ok := q.Clause.(*parser.OrClause); !ok {
+		t.Fatalf("expected *parser.OrClause but got %T", q.Clause)
+	}
+
+	if q.Pos() != 0 || q.End() != 45 {
+		t.Fatalf("expected span 0-45 but got %d-%d", q.Pos(), q.End())
+	}
+}
+
+func TestParseTrailingSemicolons(t *testing.T) {
+	for _, input := range []string{"book = john;", "book = john\n", "book = john;;\n"} {
+		if _, err := parser.Parse(input); err != nil {
+			t.Fatalf("%q: expected no error but got %s", input, err)
+		}
+	}
+}
+
+func TestParseRejectsTrailingTokens(t *testing.T) {
+	for _, input := range []string{"book = john mark", "book = john )", "book = john; text = love"} {
+		q, err := parser.Parse(input)
+		if err == nil {
+			t.Fatalf("%q: expected an error", input)
+		}
+
+		if q != nil {
+			t.Fatalf("%q: expected no query", input)
+		}
+	}
+}
+
+func TestParseListsEveryProblem(t *testing.T) {
+	_, err := parser.Parse("book = and text = or chapter")
+
+	var diags parser.Diagnostics
+	if !errors.As(err, &diags) {
+		t.Fatalf("expected error to be parser.Diagnostics but got %T", err)
+	}
+
+	if len(diags) != 3 {
+		t.Fatalf("expected 3 diagnostics but got %d: %s", len(diags), diags)
+	}
+}
+
+func TestParseEmptyQuery(t *testing.T) {
+	if _, err := parser.Parse(""); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestParseTestdata(t *testing.T) {
+	data, err := os.ReadFile("testdata/queries.bql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, input := range strings.Split(string(data), "\n") {
+		if input == "" || strings.HasPrefix(input, "#") {
+			continue
+		}
+		if _, err := parser.Parse(input); err != nil {
+			t.Fatalf("%q: expected no error but got %s", input, err)
+		}
+	}
+}
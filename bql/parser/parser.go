@@ -1,129 +1,630 @@
 package parser
 
 import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
 	"launchpad.net/kjvonly-bql/bql/state"
 )
 
 type Token struct {
 	Type  state.ElementType
 	Value interface{}
+	Pos   int // byte offset of the first character of the token
+	End   int // byte offset just past the last character of the token
+}
+
+func (t Token) String() string {
+	if s, ok := t.Value.(string); ok {
+		return fmt.Sprintf("%s %q", t.Type, s)
+	}
+	return string(t.Type)
 }
 
 type Parser struct{}
 
-func (p *Parser) ParseQuery(b *Builder) bool {
-	p.ParseOrClause(b)
-	b.AssignOrphanedExpressions(b.Expression)
-	b.Expression.Done(state.QUERY)
-	return true
+// ParseQuery parses a whole query and returns its tree along with the
+// diagnostics collected by the builder. The query was parsed successfully if
+// none of them is an error. Tokens left over after the query are reported as
+// an error.
+func (p *Parser) ParseQuery(b *Builder) (*Query, Diagnostics) {
+	start := b.CurrentToken.Pos
+	c, _ := p.ParseOrClause(b)
+	q := &Query{Clause: c}
+
+	var ok bool
+	if state.ORDER_KEYWORDS[b.GetTokenType()] {
+		if q.OrderBy, ok = p.ParseOrderBy(b); !ok {
+			p.SkipUntil(b, state.LIMIT_KEYWORDS, state.OFFSET_KEYWORDS, state.TERMINATORS)
+		}
+	}
+	if state.LIMIT_KEYWORDS[b.GetTokenType()] {
+		if q.Limit, ok = p.ParsePaging(b, state.LIMIT_KEYWORDS); !ok {
+			p.SkipUntil(b, state.OFFSET_KEYWORDS, state.TERMINATORS)
+		}
+	}
+	if state.OFFSET_KEYWORDS[b.GetTokenType()] {
+		if q.Offset, ok = p.ParsePaging(b, state.OFFSET_KEYWORDS); !ok {
+			p.SkipUntil(b, state.TERMINATORS)
+		}
+	}
+	p.ParseEndOfQuery(b)
+
+	q.Span = b.Span(start)
+	p.checkQueryFunctions(b, q)
+	return q, b.Diagnostics
 }
 
-func (p *Parser) ParseOrClause(b *Builder) bool {
-	var e *Expression
-	if !p.ParseAndClause(b) {
-		return false
+// ParseOrderBy parses "order" "by" sort_key {"," sort_key}.
+func (p *Parser) ParseOrderBy(b *Builder) ([]*SortKey, bool) {
+	if !p.AdvanceIfMatches(b, state.ORDER_KEYWORDS) {
+		b.Error("expected order", state.ORDER_KEYWORDS)
+		return nil, false
 	}
 
-	for p.AdvanceIfMatches(b, state.OR_OPERATORS) {
-		if e == nil {
-			e = &Expression{}
-			e.Value = "OR"
-			b.AssignOrphanedExpressions(e)
+	if !p.AdvanceIfMatches(b, state.BY_KEYWORDS) {
+		b.Error("expected by", state.BY_KEYWORDS)
+		return nil, false
+	}
+
+	var ks []*SortKey
+	for {
+		k, ok := p.ParseSortKey(b)
+		if !ok {
+			return ks, false
 		}
+		ks = append(ks, k)
 
-		if !p.ParseAndClause(b) {
-			// b.Errors probably need to panic or terminate parse
-			b.Error("expected clause after OR keyword")
+		if !p.AdvanceIfMatches(b, state.SEPARATORS) {
+			return ks, true
 		}
+	}
+}
 
-		b.AssignOrphanedExpressions(e)
+// ParsePaging parses a limit or offset clause, i.e. the given keyword followed
+// by a non-negative integer.
+func (p *Parser) ParsePaging(b *Builder, keyword map[state.ElementType]bool) (*NumberLiteral, bool) {
+	kw := b.CurrentToken
+	if !p.AdvanceIfMatches(b, keyword) {
+		b.Error("expected keyword", keyword)
+		return nil, false
 	}
 
-	if e != nil {
-		e.Done(state.OR_CLAUSE)
-		b.AssignOrphanedExpressions(e)
+	name := strings.ToLower(kw.Value.(string))
+	ct := b.CurrentToken
+	if !p.AdvanceIfMatches(b, state.NUMBER_LITERALS) {
+		b.Error("expected number after "+name, state.NUMBER_LITERALS)
+		return nil, false
 	}
 
-	return true
+	v, ok := p.numberLiteral(b, ct)
+	if !ok {
+		return nil, false
+	}
+	n := v.(*NumberLiteral)
+	if n.IsFloat {
+		b.ErrorAt(n, name+" must be a non-negative integer")
+		return nil, false
+	}
+	return n, true
 }
 
-func (p *Parser) ParseAndClause(b *Builder) bool {
-	var e *Expression
-	if !p.ParseTerminalClause(b) {
-		return false
+// ParseSortKey parses field ["asc" | "desc"]. Keys without a direction sort
+// in ascending order.
+func (p *Parser) ParseSortKey(b *Builder) (*SortKey, bool) {
+	start := b.CurrentToken.Pos
+	f, ok := p.ParseFieldName(b)
+	if !ok {
+		return nil, false
+	}
+
+	k := &SortKey{Field: f}
+	ct := b.CurrentToken
+	if p.AdvanceIfMatches(b, state.SORT_DIRECTIONS) {
+		k.Descending = ct.Type == state.DESC_KEYWORD
+	}
+	k.Span = b.Span(start)
+	return k, true
+}
+
+// checkQueryFunctions reports calls to query functions that do not wrap the
+// whole query.
+func (p *Parser) checkQueryFunctions(b *Builder, q *Query) {
+	Inspect(q, func(n Node) bool {
+		fc, ok := n.(*FunctionCall)
+		if !ok || Node(fc) == Node(q.Clause) {
+			return true
+		}
+		if f, ok := LookupFunction(fc.Name); ok && f.Kind == QueryFunction {
+			b.ErrorAt(fc, fmt.Sprintf("%s() must wrap the whole query", f.Name))
+		}
+		return true
+	})
+}
+
+func (p *Parser) ParseOrClause(b *Builder) (Clause, bool) {
+	start := b.CurrentToken.Pos
+	c, parsed := p.ParseAndClause(b)
+	if !parsed {
+		p.SkipUntil(b, state.OR_OPERATORS, state.RIGHT_PARENTHESIS, state.QUERY_CLAUSE_KEYWORDS)
+	}
+
+	cs := appendClause(nil, c)
+	matched := false
+	for p.AdvanceIfMatches(b, state.OR_OPERATORS) {
+		matched = true
+		oc, ok := p.ParseAndClause(b)
+		if !ok {
+			parsed = false
+			p.SkipUntil(b, state.OR_OPERATORS, state.RIGHT_PARENTHESIS, state.QUERY_CLAUSE_KEYWORDS)
+		}
+		cs = appendClause(cs, oc)
+	}
+
+	if !matched {
+		return c, parsed
+	}
+
+	return &OrClause{Span: b.Span(start), Clauses: cs}, parsed
+}
+
+func (p *Parser) ParseAndClause(b *Builder) (Clause, bool) {
+	start := b.CurrentToken.Pos
+	c, parsed := p.ParseNotExpr(b)
+	if !parsed {
+		p.SkipUntil(b, state.AND_OPERATORS, state.OR_OPERATORS, state.RIGHT_PARENTHESIS, state.QUERY_CLAUSE_KEYWORDS)
 	}
 
+	cs := appendClause(nil, c)
+	matched := false
 	for p.AdvanceIfMatches(b, state.AND_OPERATORS) {
-		if e == nil {
-			e = &Expression{}
-			e.Value = "AND"
-			b.AssignOrphanedExpressions(e)
+		matched = true
+		ac, ok := p.ParseNotExpr(b)
+		if !ok {
+			parsed = false
+			p.SkipUntil(b, state.AND_OPERATORS, state.OR_OPERATORS, state.RIGHT_PARENTHESIS, state.QUERY_CLAUSE_KEYWORDS)
 		}
+		cs = appendClause(cs, ac)
+	}
+
+	if !matched {
+		return c, parsed
+	}
+
+	return &AndClause{Span: b.Span(start), Clauses: cs}, parsed
+}
 
-		if !p.ParseTerminalClause(b) {
-			// TODO b.Errors probably need to panic or terminate parse
-			b.Error("expected clause after AND keyword")
-			return false
+func (p *Parser) ParseNotExpr(b *Builder) (Clause, bool) {
+	start := b.CurrentToken.Pos
+	switch tt := b.GetTokenType(); {
+	case state.NOT_OPERATORS[tt]:
+		b.AdvanceLexer()
+		c, ok := p.ParseNotExpr(b)
+		if c == nil {
+			return nil, false
+		}
+		return &NotClause{Span: b.Span(start), Clause: c}, ok
+	case state.LEFT_PARENTHESIS[tt]:
+		return p.ParseSubclause(b)
+	case state.FUNCTION_NAMES[tt] && b.PeekTokenType() == state.LPAR:
+		fc, ok := p.ParseFunctionCall(b, QueryFunction, ClauseFunction)
+		if fc == nil {
+			return nil, false
 		}
-		b.AssignOrphanedExpressions(e)
+		return fc, ok
+	case state.VALID_FIELD_NAMES[tt]:
+		return p.ParseTerminalClause(b)
 	}
 
-	if e != nil {
-		e.Done(state.AND_CLAUSE)
-		b.AssignOrphanedExpressions(e)
+	b.Error("expected clause", state.VALID_FIELD_NAMES, state.LEFT_PARENTHESIS, state.NOT_OPERATORS)
+	return nil, false
+}
+
+// ParseSubclause parses a parenthesized or_clause. The parentheses only
+// affect the shape of the tree, no node is created for them.
+func (p *Parser) ParseSubclause(b *Builder) (Clause, bool) {
+	if !p.AdvanceIfMatches(b, state.LEFT_PARENTHESIS) {
+		b.Error("expected (", state.LEFT_PARENTHESIS)
+		return nil, false
 	}
 
-	return true
+	c, parsed := p.ParseOrClause(b)
+	if p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS) {
+		return c, parsed
+	}
+
+	if parsed {
+		b.Error("expected )", state.AND_OPERATORS, state.OR_OPERATORS, state.RIGHT_PARENTHESIS)
+	}
+	// resume after the closing parenthesis, if any
+	p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+	p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+	return c, false
 }
 
-func (p *Parser) ParseTerminalClause(b *Builder) bool {
-	var e *Expression
-	if !p.ParseFieldName(b) {
-		return false
+func (p *Parser) ParseTerminalClause(b *Builder) (Clause, bool) {
+	start := b.CurrentToken.Pos
+	f, ok := p.ParseFieldName(b)
+	if !ok {
+		return nil, false
+	}
+
+	if state.NEAR_OPERATORS[b.GetTokenType()] {
+		v, ok := p.ParseProximity(b)
+		if !ok {
+			return nil, false
+		}
+		return &Comparison{Span: b.Span(start), Field: f, Operator: state.NEAR, Value: v}, true
+	}
+
+	op, ok := p.ParseOperator(b)
+	if !ok {
+		return nil, false
+	}
+
+	v, ok := p.ParseOperand(b)
+	if !ok {
+		return nil, false
+	}
+
+	_, isList := v.(*List)
+	switch {
+	case (op == state.IN || op == state.NOT_IN) && !isList:
+		b.ErrorAt(v, "expected list after in operator")
+		return nil, false
+	case op != state.IN && op != state.NOT_IN && isList:
+		b.ErrorAt(v, "list operands require the in or not in operator")
+		return nil, false
 	}
 
+	return &Comparison{Span: b.Span(start), Field: f, Operator: op, Value: v}, true
+}
+
+// ParseOperator parses a simple_op. The two keyword forms of the list
+// operators are reported as state.IN and state.NOT_IN.
+func (p *Parser) ParseOperator(b *Builder) (state.ElementType, bool) {
 	ct := b.CurrentToken
-	if p.AdvanceIfMatches(b, state.SIMPLE_OPERATORS) {
-		e = &Expression{}
-		p.ParseOperand(b)
+	switch {
+	case p.AdvanceIfMatches(b, state.SIMPLE_OPERATORS):
+		return ct.Type, true
+	case p.AdvanceIfMatches(b, state.IN_OPERATORS):
+		return state.IN, true
+	case p.AdvanceIfMatches(b, state.NOT_KEYWORDS):
+		if p.AdvanceIfMatches(b, state.IN_OPERATORS) {
+			return state.NOT_IN, true
+		}
+		b.Error("expected in", state.IN_OPERATORS)
+		return "", false
 	}
 
-	if e != nil {
-		e.Value = ct.Value
-		e.Done(state.SIMPLE_CLAUSE)
-		b.AssignOrphanedExpressions(e)
+	b.Error("expected operator", state.SIMPLE_OPERATORS, state.IN_OPERATORS, state.NOT_KEYWORDS, state.NEAR_OPERATORS)
+	return "", false
+}
+
+// nearOptions maps the options of the near operator to the Proximity fields
+// they set: ordered and unordered set Ordered, verse and adjacent set
+// AcrossVerses.
+var nearOptions = map[string]struct{ ordered, across, value bool }{
+	"ordered":   {ordered: true, value: true},
+	"unordered": {ordered: true, value: false},
+	"verse":     {across: true, value: false},
+	"adjacent":  {across: true, value: true},
+}
+
+// ParseProximity parses "near" "(" string "," string "," INTEGER
+// {"," near_option} ")", where the distance is a positive integer and the
+// options are ordered, unordered, verse or adjacent.
+func (p *Parser) ParseProximity(b *Builder) (*Proximity, bool) {
+	start := b.CurrentToken.Pos
+	if !p.AdvanceIfMatches(b, state.NEAR_OPERATORS) {
+		b.Error("expected near", state.NEAR_OPERATORS)
+		return nil, false
+	}
+	if !p.AdvanceIfMatches(b, state.LEFT_PARENTHESIS) {
+		b.Error("expected (", state.LEFT_PARENTHESIS)
+		return nil, false
 	}
 
-	return true
+	var args []Node
+	for !state.RIGHT_PARENTHESIS[b.GetTokenType()] {
+		k := ArgString
+		if len(args) == 2 {
+			k = ArgNumber
+		}
+
+		a, ok := p.ParseArgument(b, k)
+		if !ok {
+			p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+			p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+			return nil, false
+		}
+		args = append(args, a)
+
+		if !p.AdvanceIfMatches(b, state.SEPARATORS) {
+			break
+		}
+		if p.trailingSeparator(b) {
+			return nil, false
+		}
+	}
+
+	if !p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS) {
+		b.Error("expected , or )", state.SEPARATORS, state.RIGHT_PARENTHESIS)
+		p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+		p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+		return nil, false
+	}
+
+	x := &Proximity{Span: b.Span(start)}
+	if len(args) < 3 || len(args) > 5 {
+		b.ErrorAt(x, fmt.Sprintf("near() takes between 3 and 5 arguments, got %d", len(args)))
+		return nil, false
+	}
+	x.First, x.Second = args[0].(*StringLiteral), args[1].(*StringLiteral)
+	x.Distance = args[2].(*NumberLiteral)
+
+	parsed := true
+	if x.Distance.IsFloat || x.Distance.Int < 1 {
+		b.ErrorAt(x.Distance, "near distance must be a positive integer")
+		parsed = false
+	}
+	for _, a := range args[3:] {
+		s := a.(*StringLiteral)
+		o, ok := nearOptions[strings.ToLower(s.Value)]
+		switch {
+		case !ok:
+			b.ErrorAt(s, fmt.Sprintf("unknown near option '%s', expected ordered, unordered, verse or adjacent", s.Value))
+			parsed = false
+		case o.ordered:
+			x.Ordered = o.value
+		case o.across:
+			x.AcrossVerses = o.value
+		}
+	}
+	return x, parsed
 }
 
-func (p *Parser) ParseFieldName(b *Builder) bool {
+// trailingSeparator reports and skips a ) following the separator of an
+// argument list, as in count(book = john,).
+func (p *Parser) trailingSeparator(b *Builder) bool {
 	ct := b.CurrentToken
-	if !p.AdvanceIfMatches(b, state.VALID_FIELD_NAMES) {
-		b.Error("expected field name")
+	if !state.RIGHT_PARENTHESIS[ct.Type] {
 		return false
 	}
-	e := b.AddExpression()
-	e.Value = ct.Value
-	e.Done(state.IDENTIFIER)
+	b.ErrorAt(Span{Start: ct.Pos, Stop: ct.End}, "expected argument, found )")
+	p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
 	return true
 }
 
-func (p *Parser) ParseOperand(b *Builder) bool {
-	var e *Expression
-	parsed := true
+func (p *Parser) ParseFieldName(b *Builder) (*Field, bool) {
 	ct := b.CurrentToken
-	if p.AdvanceIfMatches(b, state.LITERALS) {
-		e = b.AddExpression()
-		e.Value = ct.Value
-		e.Done(state.LITERAL)
-	} else {
-		parsed = false
+	if !p.AdvanceIfMatches(b, state.VALID_FIELD_NAMES) {
+		b.Error("expected field name", state.VALID_FIELD_NAMES)
+		return nil, false
+	}
+	return &Field{Span: b.Span(ct.Pos), Name: ct.Value.(string)}, true
+}
+
+func (p *Parser) ParseOperand(b *Builder) (Operand, bool) {
+	switch tt := b.GetTokenType(); {
+	case state.LEFT_PARENTHESIS[tt]:
+		return p.ParseList(b)
+	case state.FUNCTION_NAMES[tt] && b.PeekTokenType() == state.LPAR:
+		fc, ok := p.ParseFunctionCall(b, OperandFunction)
+		if fc == nil {
+			return nil, false
+		}
+		return fc, ok
+	}
+	return p.ParseLiteral(b)
+}
+
+// ParseFunctionCall parses fname "(" arg_list ")". The arguments are parsed as
+// described by the registered function, allowed lists the function kinds that
+// are accepted at this point of the query.
+func (p *Parser) ParseFunctionCall(b *Builder, allowed ...FunctionKind) (*FunctionCall, bool) {
+	start := b.CurrentToken.Pos
+	ct := b.CurrentToken
+	if !p.AdvanceIfMatches(b, state.FUNCTION_NAMES) {
+		b.Error("expected function name", state.FUNCTION_NAMES)
+		return nil, false
+	}
+	name := &Field{Span: b.Span(ct.Pos), Name: ct.Value.(string)}
+
+	if !p.AdvanceIfMatches(b, state.LEFT_PARENTHESIS) {
+		b.Error("expected (", state.LEFT_PARENTHESIS)
+		return nil, false
+	}
+
+	f, ok := LookupFunction(name.Name)
+	if !ok {
+		b.ErrorAt(name, fmt.Sprintf("unknown function %s", name.Name))
+		p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+		p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+		return nil, false
+	}
+
+	parsed := false
+	for _, k := range allowed {
+		if f.Kind == k {
+			parsed = true
+		}
 	}
 	if !parsed {
-		b.Error("expected either literal")
+		article := "a"
+		if f.Kind == OperandFunction {
+			article = "an"
+		}
+		b.ErrorAt(name, fmt.Sprintf("%s() is %s %s function and cannot be used here", f.Name, article, f.Kind))
+	}
+
+	var args []Node
+	for !state.RIGHT_PARENTHESIS[b.GetTokenType()] {
+		k := ArgOperand
+		if len(args) < len(f.Args) {
+			k = f.Args[len(args)]
+		}
+
+		a, ok := p.ParseArgument(b, k)
+		if !ok {
+			p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+			p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+			return nil, false
+		}
+		args = append(args, a)
+
+		if !p.AdvanceIfMatches(b, state.SEPARATORS) {
+			break
+		}
+		if p.trailingSeparator(b) {
+			return nil, false
+		}
 	}
-	return parsed
+
+	if !p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS) {
+		b.Error("expected , or )", state.SEPARATORS, state.RIGHT_PARENTHESIS)
+		p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+		p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+		return nil, false
+	}
+
+	fc := &FunctionCall{Span: b.Span(start), Name: f.Name, Args: args}
+	if len(args) < f.MinArgs || len(args) > len(f.Args) {
+		b.ErrorAt(fc, fmt.Sprintf("%s() takes %s, got %d", f.Name, arity(f), len(args)))
+		parsed = false
+	}
+
+	return fc, parsed
+}
+
+// ParseArgument parses a function argument of the given kind.
+func (p *Parser) ParseArgument(b *Builder, k ArgKind) (Node, bool) {
+	if k == ArgClause {
+		return p.ParseOrClause(b)
+	}
+
+	v, ok := p.ParseOperand(b)
+	if !ok {
+		return nil, false
+	}
+
+	switch v.(type) {
+	case *StringLiteral:
+		ok = k != ArgNumber
+	case *NumberLiteral:
+		ok = k != ArgString
+	default:
+		ok = k == ArgOperand
+	}
+	if !ok {
+		b.ErrorAt(v, fmt.Sprintf("expected %s argument", k))
+	}
+
+	return v, ok
+}
+
+func arity(f *Function) string {
+	switch {
+	case len(f.Args) == 0:
+		return "no arguments"
+	case f.MinArgs == len(f.Args) && f.MinArgs == 1:
+		return "exactly 1 argument"
+	case f.MinArgs == len(f.Args):
+		return fmt.Sprintf("exactly %d arguments", f.MinArgs)
+	}
+	return fmt.Sprintf("between %d and %d arguments", f.MinArgs, len(f.Args))
+}
+
+// ParseList parses a parenthesized, comma separated list of operands.
+func (p *Parser) ParseList(b *Builder) (Operand, bool) {
+	start := b.CurrentToken.Pos
+	if !p.AdvanceIfMatches(b, state.LEFT_PARENTHESIS) {
+		b.Error("expected list", state.LEFT_PARENTHESIS)
+		return nil, false
+	}
+
+	var vs []Operand
+	for {
+		if state.LEFT_PARENTHESIS[b.GetTokenType()] {
+			b.Error("lists cannot be nested")
+			break
+		}
+
+		v, ok := p.ParseOperand(b)
+		if !ok {
+			break
+		}
+		vs = append(vs, v)
+
+		if p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS) {
+			return &List{Span: b.Span(start), Values: vs}, true
+		}
+
+		if !p.AdvanceIfMatches(b, state.SEPARATORS) {
+			b.Error("expected , or )", state.SEPARATORS, state.RIGHT_PARENTHESIS)
+			break
+		}
+	}
+
+	// resume after the closing parenthesis, if any
+	p.SkipUntil(b, state.RIGHT_PARENTHESIS)
+	p.AdvanceIfMatches(b, state.RIGHT_PARENTHESIS)
+	return nil, false
+}
+
+func (p *Parser) ParseLiteral(b *Builder) (Operand, bool) {
+	ct := b.CurrentToken
+	if !p.AdvanceIfMatches(b, state.LITERALS) {
+		b.Error("expected literal", state.LITERALS)
+		// an unquoted keyword such as order in text = order is the value,
+		// not the start of an order by, limit or offset clause
+		p.AdvanceIfMatches(b, state.QUERY_CLAUSE_KEYWORDS)
+		return nil, false
+	}
+
+	if state.NUMBER_LITERALS[ct.Type] {
+		return p.numberLiteral(b, ct)
+	}
+	return &StringLiteral{Span: b.Span(ct.Pos), Value: ct.Value.(string)}, true
+}
+
+// numberLiteral converts the arbitrary precision value of a number token and
+// reports values that do not fit in an int64 or float64.
+func (p *Parser) numberLiteral(b *Builder, ct Token) (Operand, bool) {
+	n := &NumberLiteral{Span: b.Span(ct.Pos)}
+	switch v := ct.Value.(type) {
+	case *big.Int:
+		if !v.IsInt64() {
+			b.ErrorAt(n, "integer literal out of range")
+			return nil, false
+		}
+		n.Int = v.Int64()
+	case *big.Float:
+		n.IsFloat = true
+		n.Float, _ = v.Float64()
+		if math.IsInf(n.Float, 0) {
+			b.ErrorAt(n, "floating-point literal out of range")
+			return nil, false
+		}
+	default:
+		panic(fmt.Sprintf("unexpected value %T for %s", ct.Value, ct.Type))
+	}
+	return n, true
+}
+
+// ParseEndOfQuery accepts optional trailing semicolons followed by the end of
+// the query.
+func (p *Parser) ParseEndOfQuery(b *Builder) bool {
+	for p.AdvanceIfMatches(b, state.TERMINATORS) {
+	}
+
+	if b.GetTokenType() != state.EOF {
+		b.Error("expected end of query", state.AND_OPERATORS, state.OR_OPERATORS, state.QUERY_CLAUSE_KEYWORDS, state.TERMINATORS, state.END_OF_QUERY)
+		return false
+	}
+	return true
 }
 
 func (p *Parser) AdvanceIfMatches(b *Builder, m map[state.ElementType]bool) bool {
@@ -135,3 +636,36 @@ func (p *Parser) AdvanceIfMatches(b *Builder, m map[state.ElementType]bool) bool
 	}
 	return false
 }
+
+// SkipUntil advances the lexer until the current token is in one of the given
+// sets or the end of the query is reached. Parenthesized groups are skipped as
+// a whole. It is used to resynchronize after an error so that later problems
+// in the query are reported as well.
+func (p *Parser) SkipUntil(b *Builder, ms ...map[state.ElementType]bool) {
+	depth := 0
+	for tt := b.GetTokenType(); tt != state.EOF; tt = b.GetTokenType() {
+		if depth == 0 {
+			for _, m := range ms {
+				if m[tt] {
+					return
+				}
+			}
+		}
+
+		switch {
+		case state.LEFT_PARENTHESIS[tt]:
+			depth++
+		case state.RIGHT_PARENTHESIS[tt] && depth > 0:
+			depth--
+		}
+		b.AdvanceLexer()
+	}
+}
+
+// appendClause appends c to cs unless c failed to parse.
+func appendClause(cs []Clause, c Clause) []Clause {
+	if c == nil {
+		return cs
+	}
+	return append(cs, c)
+}
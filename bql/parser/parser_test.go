@@ -1,6 +1,8 @@
 package parser_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"launchpad.net/kjvonly-bql/bql/parser"
@@ -11,7 +13,7 @@ func TestParseInvalidFieldName(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("="))
 	b.AdvanceLexer()
-	success := p.ParseFieldName(b)
+	_, success := p.ParseFieldName(b)
 	if success {
 		t.Fatalf("expected parseFieldName to have failed")
 	}
@@ -21,7 +23,7 @@ func TestParseValidFieldName(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book"))
 	b.AdvanceLexer()
-	success := p.ParseFieldName(b)
+	_, success := p.ParseFieldName(b)
 
 	if !success {
 		t.Fatalf("expected parseFieldName to have succeeded")
@@ -32,134 +34,126 @@ func TestParseOrClauseShouldNotSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book"))
 
-	success := p.ParseOrClause(b)
+	_, success := p.ParseOrClause(b)
 
 	if success {
 		t.Fatalf("expected not to succeed")
 	}
 }
 
-func flattenExpressions(m *parser.Expression) []*parser.Expression {
+// flattenNodes lists the nodes of the tree in pre-order.
+func flattenNodes(n parser.Node) []parser.Node {
+	ns := []parser.Node{}
+	parser.Inspect(n, func(n parser.Node) bool {
+		if n != nil {
+			ns = append(ns, n)
+		}
+		return true
+	})
+	return ns
+}
 
-	ma := []*parser.Expression{}
+func checkNodeTypes(t *testing.T, n parser.Node, expected []string) {
+	t.Helper()
 
-	ma = append(ma, m)
+	ns := flattenNodes(n)
+	if len(ns) != len(expected) {
+		t.Fatalf("expected %d nodes but got %d", len(expected), len(ns))
+	}
 
-	for i := 0; i < len(m.Expressions); i++ {
-		ma = append(ma, flattenExpressions(m.Expressions[i])...)
+	for i := 0; i < len(ns); i++ {
+		if tn := fmt.Sprintf("%T", ns[i]); expected[i] != tn {
+			t.Fatalf("expected type %s but got %s", expected[i], tn)
+		}
 	}
-	return ma
 }
 
 func TestParseQueryShouldSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book = john and book = mark or book = matthew"))
 	b.AdvanceLexer()
-	success := p.ParseQuery(b)
+	q, diags := p.ParseQuery(b)
 
-	if !success {
-		t.Fatalf("expected to succeed")
+	if diags.HasErrors() {
+		t.Fatalf("expected to succeed but got %s", diags)
 	}
 
-	expectedExpressionTypeOrdered := []state.ElementType{
-		state.QUERY,
-		state.OR_CLAUSE,
-		state.AND_CLAUSE,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
+	expectedNodeTypes := []string{
+		"*parser.Query",
+		"*parser.OrClause",
+		"*parser.AndClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
 	}
 
-	es := flattenExpressions(b.Expression)
-	for i := 0; i < len(es); i++ {
-		if expectedExpressionTypeOrdered[i] != es[i].Type {
-			t.Fatalf("expected type %s but got %s", expectedExpressionTypeOrdered[i], es[i].Type)
-		}
-	}
+	checkNodeTypes(t, q, expectedNodeTypes)
 }
 
 func TestParseOrClauseShouldSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book = john or book = mark or book = matthew"))
 	b.AdvanceLexer()
-	success := p.ParseOrClause(b)
-	b.Expression.Done(state.QUERY)
-	b.AssignOrphanedExpressions(b.Expression)
+	c, success := p.ParseOrClause(b)
 
 	if !success {
 		t.Fatalf("expected to succeed")
 	}
 
-	expectedExpressionTypeOrdered := []state.ElementType{
-		state.QUERY,
-		state.OR_CLAUSE,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
+	expectedNodeTypes := []string{
+		"*parser.OrClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
 	}
 
-	es := flattenExpressions(b.Expression)
-	for i := 0; i < len(es); i++ {
-		if expectedExpressionTypeOrdered[i] != es[i].Type {
-			t.Fatalf("expected type %s but got %s", expectedExpressionTypeOrdered[i], es[i].Type)
-		}
-	}
+	checkNodeTypes(t, c, expectedNodeTypes)
 }
 
 func TestParseAndOrClauseShouldSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book = john or book = mark and book = matthew"))
 	b.AdvanceLexer()
-	success := p.ParseOrClause(b)
-	b.Expression.Done(state.QUERY)
-	b.AssignOrphanedExpressions(b.Expression)
+	c, success := p.ParseOrClause(b)
 
 	if !success {
 		t.Fatalf("expected to succeed")
 	}
 
-	expectedExpressionTypeOrdered := []state.ElementType{
-		state.QUERY,
-		state.OR_CLAUSE,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.AND_CLAUSE,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
+	expectedNodeTypes := []string{
+		"*parser.OrClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.AndClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
 	}
 
-	es := flattenExpressions(b.Expression)
-	for i := 0; i < len(es); i++ {
-		if expectedExpressionTypeOrdered[i] != es[i].Type {
-			t.Fatalf("expected type %s but got %s", expectedExpressionTypeOrdered[i], es[i].Type)
-		}
-	}
+	checkNodeTypes(t, c, expectedNodeTypes)
 }
 
 func TestParseAndClauseShouldNotSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book"))
 
-	success := p.ParseAndClause(b)
-	b.Expression.Done(state.QUERY)
-	b.AssignOrphanedExpressions(b.Expression)
+	_, success := p.ParseAndClause(b)
 
 	if success {
 		t.Fatalf("expected not to succeed")
@@ -171,41 +165,33 @@ func TestParseAndClauseShouldSucceed(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book = john and book = mark and book = matthew"))
 	b.AdvanceLexer()
-	success := p.ParseAndClause(b)
-	b.Expression.Done(state.QUERY)
-	b.AssignOrphanedExpressions(b.Expression)
+	c, success := p.ParseAndClause(b)
 
 	if !success {
 		t.Fatalf("expected to succeed")
 	}
 
-	expectedExpressionTypeOrdered := []state.ElementType{
-		state.QUERY,
-		state.AND_CLAUSE,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
-		state.SIMPLE_CLAUSE,
-		state.IDENTIFIER,
-		state.LITERAL,
+	expectedNodeTypes := []string{
+		"*parser.AndClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
 	}
 
-	es := flattenExpressions(b.Expression)
-	for i := 0; i < len(es); i++ {
-		if expectedExpressionTypeOrdered[i] != es[i].Type {
-			t.Fatalf("expected type %s but got %s", expectedExpressionTypeOrdered[i], es[i].Type)
-		}
-	}
+	checkNodeTypes(t, c, expectedNodeTypes)
 }
 
 func TestParseTerminalClauseNotProperFieldName(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("="))
 	b.AdvanceLexer()
-	success := p.ParseTerminalClause(b)
+	_, success := p.ParseTerminalClause(b)
 
 	if success {
 		t.Fatalf("expected false")
@@ -216,29 +202,22 @@ func TestParseTerminalClauseProperFieldName(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("book = john"))
 	b.AdvanceLexer()
-	success := p.ParseTerminalClause(b)
-	b.Expression.Done(state.QUERY)
-	b.AssignOrphanedExpressions(b.Expression)
+	c, success := p.ParseTerminalClause(b)
 
 	if !success {
 		t.Fatalf("expected true")
 	}
 
-	expectedExpressionTypeOrdered := []state.ElementType{state.QUERY, state.SIMPLE_CLAUSE, state.IDENTIFIER, state.LITERAL}
+	expectedNodeTypes := []string{"*parser.Comparison", "*parser.Field", "*parser.StringLiteral"}
 
-	es := flattenExpressions(b.Expression)
-	for i := 0; i < len(expectedExpressionTypeOrdered); i++ {
-		if expectedExpressionTypeOrdered[i] != es[i].Type {
-			t.Fatalf("expected type %s but got %s", expectedExpressionTypeOrdered[i], es[i].Type)
-		}
-	}
+	checkNodeTypes(t, c, expectedNodeTypes)
 }
 
 func TestParseOperand(t *testing.T) {
 	p := parser.Parser{}
 	b := parser.NewBuilder(state.BQLLexer("john"))
 	b.AdvanceLexer()
-	parsed := p.ParseOperand(b)
+	_, parsed := p.ParseOperand(b)
 
 	if !parsed {
 		t.Fatalf("expected parsed to be true but was false")
@@ -258,3 +237,670 @@ func TestAdvanceIfMatches(t *testing.T) {
 		t.Fatalf("should match")
 	}
 }
+
+func TestParseQueryReportsDiagnostic(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("book = and"))
+	b.AdvanceLexer()
+	_, diags := p.ParseQuery(b)
+
+	// the missing literal and the dangling AND keyword
+	if len(diags) != 2 {
+		t.Fatalf("expected 2 diagnostics but got %d: %s", len(diags), diags)
+	}
+
+	d := diags[0]
+	if d.Severity != parser.SeverityError {
+		t.Fatalf("expected severity %s but got %s", parser.SeverityError, d.Severity)
+	}
+	if d.Offset != 7 || d.End != 10 {
+		t.Fatalf("expected offsets 7-10 but got %d-%d", d.Offset, d.End)
+	}
+	if d.Position.Line != 1 || d.Position.Column != 8 {
+		t.Fatalf("expected position 1:8 but got %d:%d", d.Position.Line, d.Position.Column)
+	}
+	if len(d.Expected) != len(state.LITERALS) {
+		t.Fatalf("expected %d expected token types but got %v", len(state.LITERALS), d.Expected)
+	}
+	for _, e := range d.Expected {
+		if !state.LITERALS[e] {
+			t.Fatalf("unexpected token type %s in expected set", e)
+		}
+	}
+}
+
+func TestParseQueryReportsMultipleDiagnostics(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("book = and text = or chapter"))
+	b.AdvanceLexer()
+	_, diags := p.ParseQuery(b)
+
+	expectedOffsets := []int{7, 18, 28}
+	if len(diags) != len(expectedOffsets) {
+		t.Fatalf("expected %d diagnostics but got %d: %s", len(expectedOffsets), len(diags), diags)
+	}
+	for i, o := range expectedOffsets {
+		if diags[i].Offset != o {
+			t.Fatalf("expected diagnostic %d at offset %d but got %d", i, o, diags[i].Offset)
+		}
+	}
+}
+
+func TestParseQueryReportsLexerErrors(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer(`book = "john`))
+	b.AdvanceLexer()
+	_, diags := p.ParseQuery(b)
+
+	if !diags.HasErrors() {
+		t.Fatalf("expected unterminated string to be reported")
+	}
+	if diags[0].Offset != 7 {
+		t.Fatalf("expected diagnostic at offset 7 but got %d", diags[0].Offset)
+	}
+}
+
+func TestParseSubclausePrecedence(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("book = john and (text = love or text = charity)"))
+	b.AdvanceLexer()
+	c, success := p.ParseOrClause(b)
+
+	if !success {
+		t.Fatalf("expected to succeed")
+	}
+
+	expectedNodeTypes := []string{
+		"*parser.AndClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.OrClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+	}
+
+	checkNodeTypes(t, c, expectedNodeTypes)
+}
+
+func TestParseNestedSubclauses(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("((book = john or book = mark) and text = love)"))
+	b.AdvanceLexer()
+	c, success := p.ParseNotExpr(b)
+
+	if !success {
+		t.Fatalf("expected to succeed")
+	}
+
+	expectedNodeTypes := []string{
+		"*parser.AndClause",
+		"*parser.OrClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+	}
+
+	checkNodeTypes(t, c, expectedNodeTypes)
+}
+
+func TestParseSubclauseErrors(t *testing.T) {
+	inputs := map[string]int{
+		"(book = john":              12,
+		"()":                        1,
+		"book = john)":              11,
+		"(book = ) and text = love": 8,
+		"(book = john text = love)": 13,
+	}
+
+	for input, offset := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
+
+func TestParseNotExpr(t *testing.T) {
+	inputs := map[string][]string{
+		"not book = psalms": {
+			"*parser.NotClause",
+			"*parser.Comparison",
+			"*parser.Field",
+			"*parser.StringLiteral",
+		},
+		`!(text = "sin")`: {
+			"*parser.NotClause",
+			"*parser.Comparison",
+			"*parser.Field",
+			"*parser.StringLiteral",
+		},
+		"not not book = psalms": {
+			"*parser.NotClause",
+			"*parser.NotClause",
+			"*parser.Comparison",
+			"*parser.Field",
+			"*parser.StringLiteral",
+		},
+	}
+
+	for input, expectedNodeTypes := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		c, success := p.ParseNotExpr(b)
+
+		if !success {
+			t.Fatalf("%q: expected to succeed", input)
+		}
+
+		checkNodeTypes(t, c, expectedNodeTypes)
+	}
+}
+
+func TestParseNotBindsTighterThanAnd(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("not book = psalms and text = love"))
+	b.AdvanceLexer()
+	c, success := p.ParseOrClause(b)
+
+	if !success {
+		t.Fatalf("expected to succeed")
+	}
+
+	expectedNodeTypes := []string{
+		"*parser.AndClause",
+		"*parser.NotClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+	}
+
+	checkNodeTypes(t, c, expectedNodeTypes)
+
+	n := c.(*parser.AndClause).Clauses[0]
+	if n.Pos() != 0 || n.End() != 17 {
+		t.Fatalf("expected span 0-17 but got %d-%d", n.Pos(), n.End())
+	}
+}
+
+func TestParseNotWithoutClause(t *testing.T) {
+	if _, err := parser.Parse("book = john and not"); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestParseComparisonOperators(t *testing.T) {
+	inputs := map[string]state.ElementType{
+		`book = "john"`:   state.EQ,
+		`book != "john"`:  state.NEQ,
+		`text ~ "love"`:   state.CONTAINS,
+		`text !~ "love"`:  state.NOT_CONTAINS,
+		"chapter < john":  state.LT,
+		"chapter > john":  state.GT,
+		"chapter <= john": state.LTE,
+		"chapter >= john": state.GTE,
+	}
+
+	for input, op := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		c, success := p.ParseTerminalClause(b)
+
+		if !success {
+			t.Fatalf("%q: expected to succeed", input)
+		}
+
+		cmp := c.(*parser.Comparison)
+		if cmp.Operator != op {
+			t.Fatalf("%q: expected operator %s but got %s", input, op, cmp.Operator)
+		}
+
+		if cmp.End() != len(input) {
+			t.Fatalf("%q: expected comparison to end at %d but got %d", input, len(input), cmp.End())
+		}
+	}
+}
+
+func TestParseInList(t *testing.T) {
+	inputs := map[string]state.ElementType{
+		`book in ("john", "mark", "luke")`:     state.IN,
+		`book not in ("john", "mark", "luke")`: state.NOT_IN,
+		`book IN (john,mark,luke)`:             state.IN,
+	}
+
+	for input, op := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		c, success := p.ParseTerminalClause(b)
+
+		if !success {
+			t.Fatalf("%q: expected to succeed", input)
+		}
+
+		cmp := c.(*parser.Comparison)
+		if cmp.Operator != op {
+			t.Fatalf("%q: expected operator %s but got %s", input, op, cmp.Operator)
+		}
+
+		l := cmp.Value.(*parser.List)
+		if len(l.Values) != 3 {
+			t.Fatalf("%q: expected 3 values but got %d", input, len(l.Values))
+		}
+
+		if l.End() != len(input) {
+			t.Fatalf("%q: expected list to end at %d but got %d", input, len(input), l.End())
+		}
+
+		if v := l.Values[2].(*parser.StringLiteral).Value; v != "luke" {
+			t.Fatalf("%q: expected last value luke but got %s", input, v)
+		}
+	}
+}
+
+func TestParseInListErrors(t *testing.T) {
+	inputs := map[string]int{
+		"book in john":                       8,
+		"book = (john, mark)":                7,
+		"book not (john)":                    9,
+		"book in ()":                         9,
+		"book in (john mark)":                14,
+		"book in (john, (mark))":             15,
+		"book in (john, and) or text = love": 15,
+	}
+
+	for input, offset := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
+
+func TestParseNear(t *testing.T) {
+	inputs := map[string]parser.Proximity{
+		`text near("faith", "works", 5)`:                 {},
+		`text NEAR(faith, works, 5, ordered)`:            {Ordered: true},
+		`text near("faith", works, 5, adjacent)`:         {AcrossVerses: true},
+		`text near(faith, works, 5, Adjacent, ordered)`:  {Ordered: true, AcrossVerses: true},
+		`text near(faith, works, 5, ordered, unordered)`: {},
+		`text near(faith, works, 5, verse)`:              {},
+	}
+
+	for input, exp := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		c, success := p.ParseTerminalClause(b)
+
+		if !success {
+			t.Fatalf("%q: expected to succeed: %s", input, b.Diagnostics)
+		}
+
+		cmp := c.(*parser.Comparison)
+		if cmp.Operator != state.NEAR {
+			t.Fatalf("%q: expected operator %s but got %s", input, state.NEAR, cmp.Operator)
+		}
+
+		x := cmp.Value.(*parser.Proximity)
+		if x.First.Value != "faith" || x.Second.Value != "works" || x.Distance.Int != 5 {
+			t.Fatalf("%q: unexpected terms %q and %q at distance %d", input, x.First.Value, x.Second.Value, x.Distance.Int)
+		}
+		if x.Ordered != exp.Ordered || x.AcrossVerses != exp.AcrossVerses {
+			t.Fatalf("%q: expected ordered %v and across verses %v", input, exp.Ordered, exp.AcrossVerses)
+		}
+		if x.End() != len(input) || cmp.End() != len(input) {
+			t.Fatalf("%q: expected comparison to end at %d but got %d", input, len(input), cmp.End())
+		}
+	}
+}
+
+func TestParseNearErrors(t *testing.T) {
+	inputs := map[string]string{
+		`text near "faith"`:                             "expected (",
+		`text near(faith, works)`:                       "near() takes between 3 and 5 arguments, got 2",
+		`text near(faith, works, 5, ordered, verse, x)`: "near() takes between 3 and 5 arguments, got 6",
+		`text near(faith, 5, works)`:                    "expected string argument",
+		`text near(faith, works, "5")`:                  "expected number argument",
+		`text near(faith, works, 0)`:                    "near distance must be a positive integer",
+		`text near(faith, works, 1.5)`:                  "near distance must be a positive integer",
+		`text near(faith, works, 5, close)`:             "unknown near option 'close', expected ordered, unordered, verse or adjacent",
+		`text near(faith, works, 5 ordered)`:            "expected , or )",
+		`text near("a", "b", 5,)`:                       "expected argument, found )",
+	}
+
+	for input, msg := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+		if !strings.HasPrefix(diags[0].Message, msg) {
+			t.Fatalf("%q: expected diagnostic %q but got %q", input, msg, diags[0].Message)
+		}
+	}
+}
+
+func TestParseQueryFunction(t *testing.T) {
+	q, err := parser.Parse(`count(book="john" and text="love")`)
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	expectedNodeTypes := []string{
+		"*parser.Query",
+		"*parser.FunctionCall",
+		"*parser.AndClause",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+	}
+
+	checkNodeTypes(t, q, expectedNodeTypes)
+
+	fc := q.Clause.(*parser.FunctionCall)
+	if fc.Name != "count" || fc.Pos() != 0 || fc.End() != 34 {
+		t.Fatalf("unexpected function call %s at %d-%d", fc.Name, fc.Pos(), fc.End())
+	}
+}
+
+func TestParseOperandFunction(t *testing.T) {
+	p := parser.Parser{}
+	b := parser.NewBuilder(state.BQLLexer("chapter = LAST()"))
+	b.AdvanceLexer()
+	c, success := p.ParseTerminalClause(b)
+
+	if !success {
+		t.Fatalf("expected to succeed")
+	}
+
+	checkNodeTypes(t, c, []string{"*parser.Comparison", "*parser.Field", "*parser.FunctionCall"})
+
+	fc := c.(*parser.Comparison).Value.(*parser.FunctionCall)
+	if fc.Name != "last" || len(fc.Args) != 0 {
+		t.Fatalf("unexpected function call %s with %d arguments", fc.Name, len(fc.Args))
+	}
+}
+
+func TestParseFunctionErrors(t *testing.T) {
+	inputs := map[string]int{
+		"nosuch(book = john)":                   0,
+		"count(book = john) and text = love":    0,
+		"book = john or count(text = love)":     15,
+		"last()":                                0,
+		"chapter = count(book = john)":          10,
+		"count()":                               0,
+		"count(book = john, text = love)":       24,
+		"chapter = last(":                       15,
+		"book in (john, nosuch()) and text = x": 15,
+		"count(book = john,)":                   18,
+	}
+
+	for input, offset := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
+
+func TestParseFunctionMessages(t *testing.T) {
+	inputs := map[string]string{
+		"last()":                       "last() is an operand function and cannot be used here",
+		"chapter = count(book = john)": "count() is a query function and cannot be used here",
+		"count(book = john,)":          "expected argument, found )",
+	}
+
+	for input, msg := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 || diags[0].Message != msg {
+			t.Fatalf("%q: expected diagnostic %q but got %s", input, msg, diags)
+		}
+	}
+}
+
+func TestParseNumberLiterals(t *testing.T) {
+	inputs := map[string]parser.NumberLiteral{
+		"chapter = 3":                  {Int: 3},
+		"chapter >= 150":               {Int: 150},
+		"verse < 1.5":                  {IsFloat: true, Float: 1.5},
+		"verse > .5":                   {IsFloat: true, Float: .5},
+		"chapter = 0x10":               {Int: 16},
+		"verse <= 9223372036854775807": {Int: 9223372036854775807},
+	}
+
+	for input, expected := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: expected no error but got %s", input, err)
+		}
+
+		n := q.Clause.(*parser.Comparison).Value.(*parser.NumberLiteral)
+		if n.IsFloat != expected.IsFloat || n.Int != expected.Int || n.Float != expected.Float {
+			t.Fatalf("%q: expected %+v but got %+v", input, expected, *n)
+		}
+
+		if n.End() != len(input) {
+			t.Fatalf("%q: expected literal to end at %d but got %d", input, len(input), n.End())
+		}
+	}
+}
+
+func TestParseNumberLiteralsOutOfRange(t *testing.T) {
+	inputs := map[string]int{
+		"verse = 9223372036854775808":           8,
+		"verse = 1e400":                         8,
+		"verse in (1, 2, 99999999999999999999)": 16,
+	}
+
+	for input, offset := range inputs {
+		_, err := parser.Parse(input)
+		diags, ok := err.(parser.Diagnostics)
+		if !ok || len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %v", input, err)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
+
+func TestParseOrderBy(t *testing.T) {
+	q, err := parser.Parse("book = john order by book asc, chapter DESC, verse")
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	expected := []struct {
+		field      string
+		descending bool
+	}{
+		{"book", false},
+		{"chapter", true},
+		{"verse", false},
+	}
+
+	if len(q.OrderBy) != len(expected) {
+		t.Fatalf("expected %d sort keys but got %d", len(expected), len(q.OrderBy))
+	}
+
+	for i, e := range expected {
+		k := q.OrderBy[i]
+		if k.Field.Name != e.field || k.Descending != e.descending {
+			t.Fatalf("expected sort key %s (descending %t) but got %s (descending %t)", e.field, e.descending, k.Field.Name, k.Descending)
+		}
+	}
+
+	if k := q.OrderBy[1]; k.Pos() != 31 || k.End() != 43 {
+		t.Fatalf("expected sort key span 31-43 but got %d-%d", k.Pos(), k.End())
+	}
+
+	if _, ok := q.Clause.(*parser.Comparison); !ok {
+		t.Fatalf("expected *parser.Comparison but got %T", q.Clause)
+	}
+}
+
+func TestParseOrderByErrors(t *testing.T) {
+	inputs := map[string]int{
+		"book = john order book":             18,
+		"book = john order by":               20,
+		"book = john order by book,":         26,
+		"book = john order by book up":       26,
+		"book = order by book":               7,
+		"text = order":                       7,
+		"book = john and text = order":       23,
+		"book in (john, order)":              15,
+		"(book = john order by book)":        13,
+		"book = john order by book desc asc": 31,
+	}
+
+	for input, offset := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
+
+func TestParseLimitOffset(t *testing.T) {
+	q, err := parser.Parse("book = john order by chapter limit 20 offset 40")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if n, ok := q.MaxResults(); !ok || n != 20 {
+		t.Fatalf("expected limit 20 but got %d, %v", n, ok)
+	}
+
+	if n := q.Skip(); n != 40 {
+		t.Fatalf("expected offset 40 but got %d", n)
+	}
+
+	if q.Limit.Pos() != 35 || q.Offset.End() != 47 {
+		t.Fatalf("unexpected spans %d-%d and %d-%d", q.Limit.Pos(), q.Limit.End(), q.Offset.Pos(), q.Offset.End())
+	}
+
+	checkNodeTypes(t, q, []string{
+		"*parser.Query",
+		"*parser.Comparison",
+		"*parser.Field",
+		"*parser.StringLiteral",
+		"*parser.SortKey",
+		"*parser.Field",
+		"*parser.NumberLiteral",
+		"*parser.NumberLiteral",
+	})
+}
+
+func TestParseLimitOffsetOptional(t *testing.T) {
+	inputs := map[string][2]int64{
+		"book = john":                    {-1, 0},
+		"book = john limit 0":            {0, 0},
+		"book = john offset 5":           {-1, 5},
+		"book = john LIMIT 10 OFFSET 10": {10, 10},
+	}
+
+	for input, expected := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+
+		limit, ok := q.MaxResults()
+		if !ok {
+			limit = -1
+		}
+		if limit != expected[0] || q.Skip() != expected[1] {
+			t.Fatalf("%q: expected limit %d offset %d but got %d %d", input, expected[0], expected[1], limit, q.Skip())
+		}
+	}
+}
+
+func TestParseLimitOffsetErrors(t *testing.T) {
+	inputs := map[string]int{
+		"book = john limit":                      17,
+		"book = john limit twenty":               18,
+		"book = john limit 2.5":                  18,
+		"book = john offset 1e3":                 19,
+		"book = john limit 99999999999999999999": 18,
+		"book = john offset 5 limit 10":          21,
+		"book = john limit 10 order by book":     21,
+		"book = john order by limit 10":          21,
+		"book = john limit 10 limit 10":          21,
+		"text = limit":                           7,
+		"text = offset 5 limit 10":               7,
+	}
+
+	for input, offset := range inputs {
+		p := parser.Parser{}
+		b := parser.NewBuilder(state.BQLLexer(input))
+		b.AdvanceLexer()
+		_, diags := p.ParseQuery(b)
+
+		if len(diags) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic but got %d: %s", input, len(diags), diags)
+		}
+
+		if diags[0].Offset != offset {
+			t.Fatalf("%q: expected diagnostic at offset %d but got %d", input, offset, diags[0].Offset)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+var operatorNames = map[state.ElementType]string{
+	state.EQ:           "=",
+	state.NEQ:          "!=",
+	state.CONTAINS:     "~",
+	state.NOT_CONTAINS: "!~",
+	state.LT:           "<",
+	state.GT:           ">",
+	state.LTE:          "<=",
+	state.GTE:          ">=",
+	state.IN:           "in",
+	state.NOT_IN:       "not in",
+	state.NEAR:         "near",
+}
+
+// Validate checks the fields of a syntactically valid query against the field
+// registry: the fields must be registered, accept the operators they are used
+// with and the type of the values they are compared to. Problems are recorded
+// as diagnostics. Field aliases are replaced with the names of their fields so
+// that later stages only deal with field names, and the strings compared to
+// reference fields with RefLiterals.
+func (p *Parser) Validate(b *Builder, q *Query) {
+	Inspect(q, func(n Node) bool {
+		switch n := n.(type) {
+		case *Comparison:
+			p.validateComparison(b, n)
+		case *SortKey:
+			p.validateField(b, n.Field)
+		}
+		return true
+	})
+}
+
+func (p *Parser) validateField(b *Builder, f *Field) (*FieldDef, bool) {
+	def, ok := LookupField(f.Name)
+	if !ok {
+		msg := fmt.Sprintf("unknown field '%s'", f.Name)
+		if s, ok := suggestField(f.Name); ok {
+			msg += fmt.Sprintf(", did you mean '%s'?", s)
+		}
+		b.ErrorAt(f, msg)
+		return nil, false
+	}
+	f.Name = def.Name
+	return def, true
+}
+
+func (p *Parser) validateComparison(b *Builder, c *Comparison) {
+	def, ok := p.validateField(b, c.Field)
+	if !ok {
+		return
+	}
+	if !def.Allows(c.Operator) {
+		b.ErrorAt(c, fmt.Sprintf("operator '%s' not allowed for field '%s'", operatorNames[c.Operator], def.Name))
+		return
+	}
+
+	if l, ok := c.Value.(*List); ok {
+		for i, v := range l.Values {
+			l.Values[i] = p.validateValue(b, def, v)
+		}
+		return
+	}
+	c.Value = p.validateValue(b, def, c.Value)
+}
+
+// validateValue checks that a literal has the type of the values of the field
+// and, for fields that resolve their values, e.g. book names, that it is a
+// valid value. Integer fields accept any number, e.g. verse < 1.5. The values
+// returned by function calls are not checked. The strings compared to
+// reference fields are returned as resolved RefLiterals and the integers
+// compared to fields that resolve their values, e.g. book = 40, as
+// StringLiterals; other values are returned as is.
+func (p *Parser) validateValue(b *Builder, def *FieldDef, v Operand) Operand {
+	switch v := v.(type) {
+	case *StringLiteral:
+		switch {
+		case def.Type == IntValue:
+			b.ErrorAt(v, fmt.Sprintf("expected a number for field '%s'", def.Name))
+		case def.Type == RefValue:
+			vs, err := bible.ParseReference(v.Value)
+			if err != nil {
+				msg := err.Error()
+				var e *bible.ReferenceError
+				if errors.As(err, &e) {
+					msg = e.Err
+				}
+				b.ErrorAt(v, fmt.Sprintf("invalid reference '%s': %s", v.Value, msg))
+				return v
+			}
+			return &RefLiteral{Span: v.Span, Value: v.Value, Verses: vs}
+		case def.Resolve != nil:
+			if _, ok := def.Resolve(v.Value); !ok {
+				b.ErrorAt(v, fmt.Sprintf("unknown value '%s' for field '%s'", v.Value, def.Name))
+			}
+		}
+	case *NumberLiteral:
+		switch {
+		case def.Type == IntValue:
+		case def.Resolve != nil && !v.IsFloat:
+			// e.g. book = 40, as bible.ID accepts book numbers
+			s := strconv.FormatInt(v.Int, 10)
+			if _, ok := def.Resolve(s); !ok {
+				b.ErrorAt(v, fmt.Sprintf("unknown value '%s' for field '%s'", s, def.Name))
+				return v
+			}
+			return &StringLiteral{Span: v.Span, Value: s}
+		default:
+			b.ErrorAt(v, fmt.Sprintf("expected a string for field '%s'", def.Name))
+		}
+	}
+	return v
+}
@@ -0,0 +1,162 @@
+package parser
+
+import "fmt"
+
+// Visitor has one callback per node type. Walk calls the callback matching a
+// node before walking the node's children; returning false prunes them. Leave
+// is called once the children of a visited node have been walked, whether they
+// were pruned or not.
+type Visitor interface {
+	VisitQuery(*Query) bool
+	VisitOrClause(*OrClause) bool
+	VisitAndClause(*AndClause) bool
+	VisitNotClause(*NotClause) bool
+	VisitComparison(*Comparison) bool
+	VisitField(*Field) bool
+	VisitStringLiteral(*StringLiteral) bool
+	VisitRefLiteral(*RefLiteral) bool
+	VisitNumberLiteral(*NumberLiteral) bool
+	VisitFunctionCall(*FunctionCall) bool
+	VisitList(*List) bool
+	VisitProximity(*Proximity) bool
+	VisitSortKey(*SortKey) bool
+	Leave(Node)
+}
+
+// BaseVisitor is a Visitor that walks every node and does nothing. Embed it in
+// a visitor to only implement the callbacks it needs.
+type BaseVisitor struct{}
+
+func (BaseVisitor) VisitQuery(*Query) bool                 { return true }
+func (BaseVisitor) VisitOrClause(*OrClause) bool           { return true }
+func (BaseVisitor) VisitAndClause(*AndClause) bool         { return true }
+func (BaseVisitor) VisitNotClause(*NotClause) bool         { return true }
+func (BaseVisitor) VisitComparison(*Comparison) bool       { return true }
+func (BaseVisitor) VisitField(*Field) bool                 { return true }
+func (BaseVisitor) VisitStringLiteral(*StringLiteral) bool { return true }
+func (BaseVisitor) VisitRefLiteral(*RefLiteral) bool       { return true }
+func (BaseVisitor) VisitNumberLiteral(*NumberLiteral) bool { return true }
+func (BaseVisitor) VisitFunctionCall(*FunctionCall) bool   { return true }
+func (BaseVisitor) VisitList(*List) bool                   { return true }
+func (BaseVisitor) VisitProximity(*Proximity) bool         { return true }
+func (BaseVisitor) VisitSortKey(*SortKey) bool             { return true }
+func (BaseVisitor) Leave(Node)                             {}
+
+// Walk traverses the tree rooted at n in depth-first order, calling the
+// callbacks of v for every node.
+func Walk(v Visitor, n Node) {
+	if visit(v, n) {
+		for _, c := range Children(n) {
+			Walk(v, c)
+		}
+	}
+	v.Leave(n)
+}
+
+func visit(v Visitor, n Node) bool {
+	switch n := n.(type) {
+	case *Query:
+		return v.VisitQuery(n)
+	case *OrClause:
+		return v.VisitOrClause(n)
+	case *AndClause:
+		return v.VisitAndClause(n)
+	case *NotClause:
+		return v.VisitNotClause(n)
+	case *Comparison:
+		return v.VisitComparison(n)
+	case *Field:
+		return v.VisitField(n)
+	case *StringLiteral:
+		return v.VisitStringLiteral(n)
+	case *RefLiteral:
+		return v.VisitRefLiteral(n)
+	case *NumberLiteral:
+		return v.VisitNumberLiteral(n)
+	case *FunctionCall:
+		return v.VisitFunctionCall(n)
+	case *List:
+		return v.VisitList(n)
+	case *Proximity:
+		return v.VisitProximity(n)
+	case *SortKey:
+		return v.VisitSortKey(n)
+	}
+	panic(fmt.Sprintf("parser.Walk: unexpected node type %T", n))
+}
+
+// Inspect traverses the tree rooted at n in depth-first order. It starts by
+// calling f(n); if f returns true, Inspect walks each of the children of n,
+// followed by a call of f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range Children(n) {
+		Inspect(c, f)
+	}
+	f(nil)
+}
+
+// Children returns the direct children of n in source order. Missing children
+// of a partially parsed tree are skipped.
+func Children(n Node) []Node {
+	var cs []Node
+	add := func(c Node) {
+		if c != nil {
+			cs = append(cs, c)
+		}
+	}
+
+	switch n := n.(type) {
+	case *Query:
+		add(n.Clause)
+		for _, k := range n.OrderBy {
+			add(k)
+		}
+		if n.Limit != nil {
+			add(n.Limit)
+		}
+		if n.Offset != nil {
+			add(n.Offset)
+		}
+	case *OrClause:
+		for _, c := range n.Clauses {
+			add(c)
+		}
+	case *AndClause:
+		for _, c := range n.Clauses {
+			add(c)
+		}
+	case *NotClause:
+		add(n.Clause)
+	case *Comparison:
+		if n.Field != nil {
+			add(n.Field)
+		}
+		add(n.Value)
+	case *FunctionCall:
+		for _, a := range n.Args {
+			add(a)
+		}
+	case *List:
+		for _, v := range n.Values {
+			add(v)
+		}
+	case *Proximity:
+		if n.First != nil {
+			add(n.First)
+		}
+		if n.Second != nil {
+			add(n.Second)
+		}
+		if n.Distance != nil {
+			add(n.Distance)
+		}
+	case *SortKey:
+		if n.Field != nil {
+			add(n.Field)
+		}
+	}
+	return cs
+}
@@ -0,0 +1,108 @@
+package parser_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+)
+
+// fieldCollector collects field names and counts the clauses it leaves.
+type fieldCollector struct {
+	parser.BaseVisitor
+	fields []string
+	left   int
+	prune  bool
+}
+
+func (v *fieldCollector) VisitField(f *parser.Field) bool {
+	v.fields = append(v.fields, f.Name)
+	return true
+}
+
+func (v *fieldCollector) VisitAndClause(*parser.AndClause) bool {
+	return !v.prune
+}
+
+func (v *fieldCollector) Leave(n parser.Node) {
+	if _, ok := n.(parser.Clause); ok {
+		v.left++
+	}
+}
+
+func TestWalk(t *testing.T) {
+	q, err := parser.Parse("book = john or text = love and chapter = 3")
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	v := &fieldCollector{}
+	parser.Walk(v, q)
+
+	if fs := strings.Join(v.fields, ","); fs != "book,text,chapter" {
+		t.Fatalf("expected fields book,text,chapter but got %s", fs)
+	}
+
+	// or, comparison, and, comparison, comparison
+	if v.left != 5 {
+		t.Fatalf("expected to leave 5 clauses but left %d", v.left)
+	}
+}
+
+func TestWalkPrune(t *testing.T) {
+	q, err := parser.Parse("book = john or text = love and chapter = 3")
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	v := &fieldCollector{prune: true}
+	parser.Walk(v, q)
+
+	if fs := strings.Join(v.fields, ","); fs != "book" {
+		t.Fatalf("expected fields book but got %s", fs)
+	}
+
+	// or, comparison, and
+	if v.left != 3 {
+		t.Fatalf("expected to leave 3 clauses but left %d", v.left)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	q, err := parser.Parse("book = john and text = love")
+	if err != nil {
+		t.Fatalf("expected no error but got %s", err)
+	}
+
+	var sb strings.Builder
+	parser.Inspect(q, func(n parser.Node) bool {
+		switch n := n.(type) {
+		case nil:
+			sb.WriteString(")")
+		case *parser.Comparison:
+			fmt.Fprintf(&sb, "(%s", n.Operator)
+			return false
+		default:
+			fmt.Fprintf(&sb, "(%T", n)
+		}
+		return true
+	})
+
+	expected := "(*parser.Query(*parser.AndClause(EQ(EQ))"
+	if sb.String() != expected {
+		t.Fatalf("expected %s but got %s", expected, sb.String())
+	}
+}
+
+func TestChildrenSkipsMissingNodes(t *testing.T) {
+	c := &parser.Comparison{Value: &parser.StringLiteral{Value: "john"}}
+
+	if cs := parser.Children(c); len(cs) != 1 {
+		t.Fatalf("expected 1 child but got %d", len(cs))
+	}
+
+	if cs := parser.Children(&parser.Query{}); len(cs) != 0 {
+		t.Fatalf("expected no children but got %d", len(cs))
+	}
+}
@@ -0,0 +1,84 @@
+package sqlquery
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect describes the SQL features that differ between databases.
+type Dialect struct {
+	Name string
+
+	// Placeholder returns the bind parameter of the n-th argument of a
+	// statement, starting at 1.
+	Placeholder func(n int) string
+
+	// Contains returns the condition matching the ~ operator, and the =
+	// operator on text fields, i.e. whether column contains the text bound
+	// to param, along with the value to bind.
+	Contains func(column, param, text string) (cond string, arg any)
+
+	// NoLimit is the LIMIT value used when a query has an offset but no
+	// limit; it is empty if the dialect accepts OFFSET without LIMIT.
+	NoLimit string
+}
+
+var (
+	// SQLite matches ~ with LIKE, which is case insensitive for ASCII
+	// letters.
+	SQLite = Dialect{
+		Name:        "sqlite",
+		Placeholder: question,
+		Contains:    like,
+		NoLimit:     "-1",
+	}
+
+	// SQLiteFTS5 matches ~ with the MATCH operator of an FTS5 virtual table.
+	// The column of the text field must be a column of that table.
+	SQLiteFTS5 = Dialect{
+		Name:        "sqlite-fts5",
+		Placeholder: question,
+		Contains:    fts5Match,
+		NoLimit:     "-1",
+	}
+
+	// PostgreSQL matches ~ with ILIKE.
+	PostgreSQL = Dialect{
+		Name:        "postgresql",
+		Placeholder: dollar,
+		Contains:    ilike,
+	}
+
+	// PostgreSQLFullText matches ~ with a tsvector full-text search using the
+	// english configuration. The words of the text must appear in order and
+	// next to each other, as with phraseto_tsquery.
+	PostgreSQLFullText = Dialect{
+		Name:        "postgresql-fts",
+		Placeholder: dollar,
+		Contains:    tsquery,
+	}
+)
+
+func question(int) string { return "?" }
+
+func dollar(n int) string { return "$" + strconv.Itoa(n) }
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func like(column, param, text string) (string, any) {
+	return column + ` LIKE ` + param + ` ESCAPE '\'`, "%" + likeEscaper.Replace(text) + "%"
+}
+
+func ilike(column, param, text string) (string, any) {
+	return column + ` ILIKE ` + param + ` ESCAPE '\'`, "%" + likeEscaper.Replace(text) + "%"
+}
+
+// fts5Match binds text as a single FTS5 phrase so that it cannot inject query
+// syntax.
+func fts5Match(column, param, text string) (string, any) {
+	return column + ` MATCH ` + param, `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+func tsquery(column, param, text string) (string, any) {
+	return `to_tsvector('english', ` + column + `) @@ phraseto_tsquery('english', ` + param + `)`, text
+}
@@ -0,0 +1,273 @@
+// Package sqlquery translates BQL queries to SQL for verse stores kept in a
+// relational table.
+//
+// The translation is made of the WHERE, ORDER BY, LIMIT and OFFSET clauses of
+// a SELECT statement; the caller provides the rest of the statement. Literals
+// of the query are never inlined in the SQL text, they are returned as bind
+// arguments.
+package sqlquery
+
+import (
+	"fmt"
+	"strings"
+
+	"launchpad.net/kjvonly-bql/bql/bible"
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// DefaultColumns maps the BQL fields to the columns of a
+// verses(book, chapter, verse, text) table.
+var DefaultColumns = map[string]string{
+	"book":    "book",
+	"chapter": "chapter",
+	"verse":   "verse",
+	"text":    "text",
+}
+
+var operators = map[state.ElementType]string{
+	state.EQ:  "=",
+	state.NEQ: "<>",
+	state.LT:  "<",
+	state.GT:  ">",
+	state.LTE: "<=",
+	state.GTE: ">=",
+}
+
+// Translator translates queries to the SQL of a dialect.
+type Translator struct {
+	Dialect Dialect
+
+	// Columns maps field names to column names. Fields missing from the map
+	// are reported as errors. DefaultColumns is used if Columns is nil.
+	Columns map[string]string
+
+	// BookOrder is the SQL expression sorting the verses by book in
+	// canonical order. If it is empty, the book column is mapped to its
+	// canonical index with a CASE expression.
+	BookOrder string
+}
+
+// Result is the translation of a query. Where, OrderBy, Limit and Offset do
+// not include their keywords and are empty if the query has no such clause.
+type Result struct {
+	Where   string
+	OrderBy string
+	Limit   string
+	Offset  string
+
+	// Count is true if the query asked for the number of matching verses
+	// rather than the verses, e.g. count(book = john).
+	Count bool
+
+	// Args holds the values of the bind parameters in order.
+	Args []any
+}
+
+// String returns the clauses of r as they follow the FROM clause of a SELECT
+// statement.
+func (r *Result) String() string {
+	var cs []string
+	if r.Where != "" {
+		cs = append(cs, "WHERE "+r.Where)
+	}
+	if r.OrderBy != "" {
+		cs = append(cs, "ORDER BY "+r.OrderBy)
+	}
+	if r.Limit != "" {
+		cs = append(cs, "LIMIT "+r.Limit)
+	}
+	if r.Offset != "" {
+		cs = append(cs, "OFFSET "+r.Offset)
+	}
+	return strings.Join(cs, " ")
+}
+
+// Translate translates q with the given dialect and the default columns.
+func Translate(q *parser.Query, d Dialect) (*Result, error) {
+	t := Translator{Dialect: d}
+	return t.Translate(q)
+}
+
+// Translate translates q. It fails on the constructs that have no SQL
+// equivalent, such as the last() function.
+func (t *Translator) Translate(q *parser.Query) (*Result, error) {
+	c, count, err := q.Selection()
+	if err != nil {
+		return nil, err
+	}
+	r := &Result{Count: count}
+	if r.Where, err = t.clause(r, c); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(q.OrderBy))
+	for i, k := range q.OrderBy {
+		col, err := t.column(k.Field)
+		if err != nil {
+			return nil, err
+		}
+		if strings.EqualFold(k.Field.Name, "book") {
+			col = t.bookOrder(col)
+		}
+		if k.Descending {
+			col += " DESC"
+		}
+		keys[i] = col
+	}
+	r.OrderBy = strings.Join(keys, ", ")
+
+	if q.Limit != nil {
+		r.Limit = t.bind(r, q.Limit.Int)
+	}
+	if q.Offset != nil {
+		if r.Limit == "" {
+			r.Limit = t.Dialect.NoLimit
+		}
+		r.Offset = t.bind(r, q.Offset.Int)
+	}
+	return r, nil
+}
+
+// bind adds v to the arguments of r and returns its placeholder.
+func (t *Translator) bind(r *Result, v any) string {
+	r.Args = append(r.Args, v)
+	return t.Dialect.Placeholder(len(r.Args))
+}
+
+func (t *Translator) clause(r *Result, c parser.Clause) (string, error) {
+	switch c := c.(type) {
+	case *parser.OrClause:
+		return t.clauses(r, c.Clauses, " OR ")
+	case *parser.AndClause:
+		return t.clauses(r, c.Clauses, " AND ")
+	case *parser.NotClause:
+		s, err := t.clause(r, c.Clause)
+		if err != nil {
+			return "", err
+		}
+		return "NOT (" + s + ")", nil
+	case *parser.Comparison:
+		return t.comparison(r, c)
+	case *parser.FunctionCall:
+		return "", unsupported(c, "function "+c.Name)
+	}
+	return "", unsupported(c, fmt.Sprintf("%T", c))
+}
+
+// clauses joins the translation of cs with sep, parenthesizing the composite
+// clauses.
+func (t *Translator) clauses(r *Result, cs []parser.Clause, sep string) (string, error) {
+	ss := make([]string, len(cs))
+	for i, c := range cs {
+		s, err := t.clause(r, c)
+		if err != nil {
+			return "", err
+		}
+		switch c.(type) {
+		case *parser.OrClause, *parser.AndClause:
+			s = "(" + s + ")"
+		}
+		ss[i] = s
+	}
+	return strings.Join(ss, sep), nil
+}
+
+func (t *Translator) comparison(r *Result, c *parser.Comparison) (string, error) {
+	col, err := t.column(c.Field)
+	if err != nil {
+		return "", err
+	}
+
+	op := c.Operator
+	if ty, _ := parser.FieldType(c.Field.Name); ty == parser.TextValue {
+		// text = has the contains semantics of ~
+		switch op {
+		case state.EQ:
+			op = state.CONTAINS
+		case state.NEQ:
+			op = state.NOT_CONTAINS
+		}
+	}
+
+	switch op {
+	case state.NEAR:
+		return "", unsupported(c, "near")
+	case state.IN, state.NOT_IN:
+		l, ok := c.Value.(*parser.List)
+		if !ok {
+			return "", unsupported(c.Value, "non-list operand of in")
+		}
+		ps := make([]string, len(l.Values))
+		for i, v := range l.Values {
+			a, err := value(c.Field, v)
+			if err != nil {
+				return "", err
+			}
+			ps[i] = t.bind(r, a)
+		}
+		in := " IN ("
+		if c.Operator == state.NOT_IN {
+			in = " NOT IN ("
+		}
+		return col + in + strings.Join(ps, ", ") + ")", nil
+	case state.CONTAINS, state.NOT_CONTAINS:
+		s, ok := c.Value.(*parser.StringLiteral)
+		if !ok {
+			return "", unsupported(c.Value, "non-string operand of ~")
+		}
+		cond, arg := t.Dialect.Contains(col, t.Dialect.Placeholder(len(r.Args)+1), s.Value)
+		r.Args = append(r.Args, arg)
+		if op == state.NOT_CONTAINS {
+			cond = "NOT (" + cond + ")"
+		}
+		return cond, nil
+	}
+
+	a, err := value(c.Field, c.Value)
+	if err != nil {
+		return "", err
+	}
+	return col + " " + operators[c.Operator] + " " + t.bind(r, a), nil
+}
+
+func (t *Translator) column(f *parser.Field) (string, error) {
+	cols := t.Columns
+	if cols == nil {
+		cols = DefaultColumns
+	}
+	if col, ok := cols[strings.ToLower(f.Name)]; ok {
+		return col, nil
+	}
+	return "", fmt.Errorf("sqlquery: %d: no column for field %s", f.Pos(), f.Name)
+}
+
+func (t *Translator) bookOrder(col string) string {
+	if t.BookOrder != "" {
+		return t.BookOrder
+	}
+
+	var sb strings.Builder
+	sb.WriteString("CASE lower(" + col + ")")
+	for i, b := range bible.Books {
+		fmt.Fprintf(&sb, " WHEN '%s' THEN %d", strings.ToLower(b), i)
+	}
+	fmt.Fprintf(&sb, " ELSE %d END", len(bible.Books))
+	return sb.String()
+}
+
+// value returns the bind argument of a literal compared to f. Strings are
+// passed in canonical form, e.g. book names as in bible.Books.
+func value(f *parser.Field, o parser.Operand) (any, error) {
+	if v, ok := parser.LiteralValue(f.Name, o); ok {
+		return v, nil
+	}
+	if fc, ok := o.(*parser.FunctionCall); ok {
+		return nil, unsupported(fc, "function "+fc.Name)
+	}
+	return nil, unsupported(o, fmt.Sprintf("%T", o))
+}
+
+func unsupported(n parser.Node, what string) error {
+	return fmt.Errorf("sqlquery: %d: %s has no SQL translation", n.Pos(), what)
+}
@@ -0,0 +1,246 @@
+package sqlquery_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/parser"
+	"launchpad.net/kjvonly-bql/bql/sqlquery"
+	"launchpad.net/kjvonly-bql/bql/state"
+)
+
+// registerTestament registers testament, a field with no column, until the
+// end of the test.
+func registerTestament(t *testing.T) {
+	t.Cleanup(parser.RegisterField(parser.FieldDef{Name: "testament", Type: parser.EnumValue, Operators: []state.ElementType{state.EQ}}))
+}
+
+func translate(t *testing.T, input string, d sqlquery.Dialect) *sqlquery.Result {
+	t.Helper()
+	q, err := parser.Parse(input)
+	if err != nil {
+		t.Fatalf("%q: unexpected error: %s", input, err)
+	}
+	r, err := sqlquery.Translate(q, d)
+	if err != nil {
+		t.Fatalf("%q: unexpected error: %s", input, err)
+	}
+	return r
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		input   string
+		dialect sqlquery.Dialect
+		sql     string
+		args    []any
+	}{
+		{
+			`book = john and chapter >= 3`, sqlquery.SQLite,
+			`WHERE book = ? AND chapter >= ?`,
+			[]any{"John", int64(3)},
+		},
+		{
+			`book = john and chapter >= 3`, sqlquery.PostgreSQL,
+			`WHERE book = $1 AND chapter >= $2`,
+			[]any{"John", int64(3)},
+		},
+		{
+			`(book = john or book != mark) and not verse < 1.5`, sqlquery.PostgreSQL,
+			`WHERE (book = $1 OR book <> $2) AND NOT (verse < $3)`,
+			[]any{"John", "Mark", 1.5},
+		},
+		{
+			`book in (jn, 41) or chapter not in (1, 2)`, sqlquery.SQLite,
+			`WHERE book IN (?, ?) OR chapter NOT IN (?, ?)`,
+			[]any{"John", "Mark", int64(1), int64(2)},
+		},
+		{
+			`text ~ "100%_sure" and text !~ love`, sqlquery.SQLite,
+			`WHERE text LIKE ? ESCAPE '\' AND NOT (text LIKE ? ESCAPE '\')`,
+			[]any{`%100\%\_sure%`, "%love%"},
+		},
+		{
+			`text ~ "love"`, sqlquery.PostgreSQL,
+			`WHERE text ILIKE $1 ESCAPE '\'`,
+			[]any{"%love%"},
+		},
+		{
+			`text ~ "say \"amen\""`, sqlquery.SQLiteFTS5,
+			`WHERE text MATCH ?`,
+			[]any{`"say ""amen"""`},
+		},
+		{
+			`book = john and text ~ "love one another"`, sqlquery.PostgreSQLFullText,
+			`WHERE book = $1 AND to_tsvector('english', text) @@ phraseto_tsquery('english', $2)`,
+			[]any{"John", "love one another"},
+		},
+		{
+			`text = "god so loved" and t != "world"`, sqlquery.SQLite,
+			`WHERE text LIKE ? ESCAPE '\' AND NOT (text LIKE ? ESCAPE '\')`,
+			[]any{"%god so loved%", "%world%"},
+		},
+		{
+			`text = "god so loved" and t != "world"`, sqlquery.SQLiteFTS5,
+			`WHERE text MATCH ? AND NOT (text MATCH ?)`,
+			[]any{`"god so loved"`, `"world"`},
+		},
+		{
+			`text = "god so loved" and t != "world"`, sqlquery.PostgreSQL,
+			`WHERE text ILIKE $1 ESCAPE '\' AND NOT (text ILIKE $2 ESCAPE '\')`,
+			[]any{"%god so loved%", "%world%"},
+		},
+		{
+			`text = "god so loved" and t != "world"`, sqlquery.PostgreSQLFullText,
+			`WHERE to_tsvector('english', text) @@ phraseto_tsquery('english', $1) AND NOT (to_tsvector('english', text) @@ phraseto_tsquery('english', $2))`,
+			[]any{"god so loved", "world"},
+		},
+		{
+			`text ~ love order by chapter desc, verse limit 20 offset 40`, sqlquery.PostgreSQL,
+			`WHERE text ILIKE $1 ESCAPE '\' ORDER BY chapter DESC, verse LIMIT $2 OFFSET $3`,
+			[]any{"%love%", int64(20), int64(40)},
+		},
+		{
+			`text ~ love offset 40`, sqlquery.SQLite,
+			`WHERE text LIKE ? ESCAPE '\' LIMIT -1 OFFSET ?`,
+			[]any{"%love%", int64(40)},
+		},
+		{
+			`text ~ love offset 40`, sqlquery.PostgreSQL,
+			`WHERE text ILIKE $1 ESCAPE '\' OFFSET $2`,
+			[]any{"%love%", int64(40)},
+		},
+	}
+
+	for _, tt := range tests {
+		r := translate(t, tt.input, tt.dialect)
+		if s := r.String(); s != tt.sql {
+			t.Fatalf("%q (%s): expected\n%s\nbut got\n%s", tt.input, tt.dialect.Name, tt.sql, s)
+		}
+		if !reflect.DeepEqual(r.Args, tt.args) {
+			t.Fatalf("%q (%s): expected arguments %#v but got %#v", tt.input, tt.dialect.Name, tt.args, r.Args)
+		}
+	}
+}
+
+// TestTranslateFullTextWordOrder checks that full-text searches match the
+// words of the text as a phrase rather than in any order.
+func TestTranslateFullTextWordOrder(t *testing.T) {
+	r := translate(t, `text ~ "another one love" and t != "world so god"`, sqlquery.PostgreSQLFullText)
+	const sql = `WHERE to_tsvector('english', text) @@ phraseto_tsquery('english', $1) AND NOT (to_tsvector('english', text) @@ phraseto_tsquery('english', $2))`
+	if s := r.String(); s != sql {
+		t.Fatalf("expected\n%s\nbut got\n%s", sql, s)
+	}
+	if args := []any{"another one love", "world so god"}; !reflect.DeepEqual(r.Args, args) {
+		t.Fatalf("expected arguments %#v but got %#v", args, r.Args)
+	}
+}
+
+func TestTranslateCount(t *testing.T) {
+	r := translate(t, `count(book = john)`, sqlquery.SQLite)
+	if !r.Count || r.Where != "book = ?" {
+		t.Fatalf("expected a count of book = ? but got %+v", r)
+	}
+}
+
+func TestTranslateBookOrder(t *testing.T) {
+	r := translate(t, `text ~ love order by book desc`, sqlquery.SQLite)
+	if !strings.HasPrefix(r.OrderBy, "CASE lower(book) WHEN 'genesis' THEN 0 WHEN 'exodus' THEN 1 ") ||
+		!strings.HasSuffix(r.OrderBy, " WHEN 'revelation' THEN 65 ELSE 66 END DESC") {
+		t.Fatalf("unexpected book order %s", r.OrderBy)
+	}
+
+	q, _ := parser.Parse(`text ~ love order by book`)
+	tr := sqlquery.Translator{Dialect: sqlquery.SQLite, BookOrder: "book_id"}
+	r, err := tr.Translate(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.OrderBy != "book_id" {
+		t.Fatalf("expected to order by book_id but got %s", r.OrderBy)
+	}
+}
+
+func TestTranslateNeverInlinesLiterals(t *testing.T) {
+	const evil = `x'); DROP TABLE verses; --`
+	input := `text = "` + evil + `" or text ~ "` + evil + `" or text != "` + evil + `"`
+
+	for _, d := range []sqlquery.Dialect{sqlquery.SQLite, sqlquery.SQLiteFTS5, sqlquery.PostgreSQL, sqlquery.PostgreSQLFullText} {
+		r := translate(t, input, d)
+		if strings.Contains(r.String(), "DROP") {
+			t.Fatalf("%s: literal inlined in %s", d.Name, r)
+		}
+		if len(r.Args) != 3 {
+			t.Fatalf("%s: expected 3 arguments but got %d", d.Name, len(r.Args))
+		}
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	registerTestament(t)
+	inputs := map[string]string{
+		"chapter = last()":               "sqlquery: 10: function last has no SQL translation",
+		"testament = new":                "sqlquery: 0: no column for field testament",
+		"book = john order by testament": "sqlquery: 21: no column for field testament",
+		`ref = "John 3:16"`:              "sqlquery: 0: no column for field ref",
+		"text near(faith, works, 5)":     "sqlquery: 0: near has no SQL translation",
+	}
+
+	for input, msg := range inputs {
+		q, err := parser.Parse(input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %s", input, err)
+		}
+		if _, err := sqlquery.Translate(q, sqlquery.SQLite); err == nil || err.Error() != msg {
+			t.Fatalf("%q: expected error %q but got %v", input, msg, err)
+		}
+	}
+}
+
+// TestTranslateNumberContains checks trees that validation would reject, as
+// they can still be built by hand.
+func TestTranslateNumberContains(t *testing.T) {
+	q, err := parser.Parse(`text ~ "3"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	c := q.Clause.(*parser.Comparison)
+	c.Value = &parser.NumberLiteral{Span: c.Value.(*parser.StringLiteral).Span, Int: 3}
+
+	const msg = "sqlquery: 7: non-string operand of ~ has no SQL translation"
+	if _, err := sqlquery.Translate(q, sqlquery.SQLite); err == nil || err.Error() != msg {
+		t.Fatalf("expected error %q but got %v", msg, err)
+	}
+}
+
+// TestTranslateMalformed checks that trees Parse does not produce are
+// rejected rather than translated.
+func TestTranslateMalformed(t *testing.T) {
+	s := &parser.StringLiteral{Span: parser.Span{Start: 8, Stop: 12}, Value: "john"}
+	queries := map[string]*parser.Query{
+		"sqlquery: 8: non-list operand of in has no SQL translation": {Clause: &parser.Comparison{Field: &parser.Field{Name: "book"}, Operator: state.IN, Value: s}},
+		"parser: 0: count() takes a single clause argument":          {Clause: &parser.FunctionCall{Name: "count", Args: []parser.Node{s}}},
+	}
+
+	for msg, q := range queries {
+		if _, err := sqlquery.Translate(q, sqlquery.SQLite); err == nil || err.Error() != msg {
+			t.Fatalf("expected error %q but got %v", msg, err)
+		}
+	}
+}
+
+func TestTranslateColumns(t *testing.T) {
+	q, _ := parser.Parse("book = john and text ~ love")
+	tr := sqlquery.Translator{
+		Dialect: sqlquery.PostgreSQL,
+		Columns: map[string]string{"book": "v.book_name", "text": "v.body"},
+	}
+	r, err := tr.Translate(q)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.Where != `v.book_name = $1 AND v.body ILIKE $2 ESCAPE '\'` {
+		t.Fatalf("unexpected translation %s", r.Where)
+	}
+}
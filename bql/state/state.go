@@ -27,25 +27,85 @@ const (
 
 	BqlANDKeyword // 13 and
 	BqlORKeyword  // 14 or
+	BqlNOTKeyword // 15 not
+	BqlNOT        // 16 !
+
+	BqlNEQ       // 17 !=
+	BqlCONTAINS  // 18 ~
+	BqlNCONTAINS // 19 !~
+	BqlLT        // 20 <
+	BqlGT        // 21 >
+	BqlLTE       // 22 <=
+	BqlGTE       // 23 >=
+
+	BqlINKeyword // 24 in
+
+	BqlORDERKeyword // 25 order
+	BqlBYKeyword    // 26 by
+	BqlASCKeyword   // 27 asc
+	BqlDESCKeyword  // 28 desc
+
+	BqlLIMITKeyword  // 29 limit
+	BqlOFFSETKeyword // 30 offset
+
+	BqlNEARKeyword // 31 near
 )
 
 var TokenTypes = map[lex.Token]ElementType{
-	lex.Error:     "error",
-	BqlEOF:        "EOF",
-	BqlSemiColon:  "semicolon",
-	BqlInt:        "integer",
-	BqlFloat:      "float",
+	lex.Error:     ERROR,
+	BqlEOF:        EOF,
+	BqlSemiColon:  SEMICOLON,
+	BqlInt:        INT_LITERAL,
+	BqlFloat:      FLOAT_LITERAL,
 	BqlString:     "STRING_LITERAL",
 	BqlChar:       "char",
 	BqlIdentifier: "IDENTIFIER",
 	BqlDot:        "dot",
 	BqlRawChar:    "raw char",
-	BqlLPAR:       "LPAR",
-	BqlRPAR:       "RPAR",
-	BqlComma:      "COMMA",
+	BqlLPAR:       LPAR,
+	BqlRPAR:       RPAR,
+	BqlComma:      COMMA,
 	BqlEQ:         "EQ",
-	BqlANDKeyword: "AND_KEYWORD",
-	BqlORKeyword:  "OR_KEYWORD",
+	BqlANDKeyword: AND_KEYWORD,
+	BqlORKeyword:  OR_KEYWORD,
+	BqlNOTKeyword: NOT_KEYWORD,
+	BqlNOT:        NOT,
+	BqlNEQ:        NEQ,
+	BqlCONTAINS:   CONTAINS,
+	BqlNCONTAINS:  NOT_CONTAINS,
+	BqlLT:         LT,
+	BqlGT:         GT,
+	BqlLTE:        LTE,
+	BqlGTE:        GTE,
+	BqlINKeyword:  IN_KEYWORD,
+
+	BqlORDERKeyword: ORDER_KEYWORD,
+	BqlBYKeyword:    BY_KEYWORD,
+	BqlASCKeyword:   ASC_KEYWORD,
+	BqlDESCKeyword:  DESC_KEYWORD,
+
+	BqlLIMITKeyword:  LIMIT_KEYWORD,
+	BqlOFFSETKeyword: OFFSET_KEYWORD,
+
+	BqlNEARKeyword: NEAR_KEYWORD,
+}
+
+// keywords maps the lower case spelling of BQL keywords to their token type.
+var keywords = map[string]lex.Token{
+	"and": BqlANDKeyword,
+	"or":  BqlORKeyword,
+	"not": BqlNOTKeyword,
+	"in":  BqlINKeyword,
+
+	"order": BqlORDERKeyword,
+	"by":    BqlBYKeyword,
+	"asc":   BqlASCKeyword,
+	"desc":  BqlDESCKeyword,
+
+	"limit":  BqlLIMITKeyword,
+	"offset": BqlOFFSETKeyword,
+
+	"near": BqlNEARKeyword,
 }
 
 // bqlInit returns the initial state function for our language.
@@ -66,6 +126,8 @@ func bqlInit() lex.StateFn {
 		// get current rune (read for us by the lexer upon entering the initial state)
 		r := s.Next()
 		pos := s.Pos()
+		// the number state functions emit their token at TokenPos
+		s.StartToken(pos)
 		// THE big switch
 		switch r {
 		case lex.EOF:
@@ -108,6 +170,38 @@ func bqlInit() lex.StateFn {
 		case ',':
 			s.Emit(pos, BqlComma, r)
 			return nil
+
+		case '!':
+			switch s.Peek() {
+			case '=':
+				s.Next()
+				s.Emit(pos, BqlNEQ, "!=")
+			case '~':
+				s.Next()
+				s.Emit(pos, BqlNCONTAINS, "!~")
+			default:
+				s.Emit(pos, BqlNOT, "!")
+			}
+			return nil
+		case '~':
+			s.Emit(pos, BqlCONTAINS, "~")
+			return nil
+		case '<':
+			if s.Peek() == '=' {
+				s.Next()
+				s.Emit(pos, BqlLTE, "<=")
+				return nil
+			}
+			s.Emit(pos, BqlLT, "<")
+			return nil
+		case '>':
+			if s.Peek() == '=' {
+				s.Next()
+				s.Emit(pos, BqlGTE, ">=")
+				return nil
+			}
+			s.Emit(pos, BqlGT, ">")
+			return nil
 		}
 
 		// we're left with identifiers, spaces and raw chars.
@@ -144,13 +238,8 @@ func identifier() lex.StateFn {
 		// the character returned by the last call to next is not part of the identifier. Undo it.
 		l.Backup()
 
-		if strings.ToLower(string(b)) == "and" {
-			l.Emit(pos, BqlANDKeyword, string(b))
-			return nil
-		}
-
-		if strings.ToLower(string(b)) == "or" {
-			l.Emit(pos, BqlORKeyword, string(b))
+		if t, ok := keywords[strings.ToLower(string(b))]; ok {
+			l.Emit(pos, t, string(b))
 			return nil
 		}
 
@@ -161,6 +250,6 @@ func identifier() lex.StateFn {
 
 // BQL: a lexer for a Bible Query Language language.
 func BQLLexer(input string) *lex.Lexer {
-	inputFile := lex.NewFile("example", strings.NewReader(input))
+	inputFile := lex.NewFile("query", strings.NewReader(input))
 	return lex.NewLexer(inputFile, bqlInit())
 }
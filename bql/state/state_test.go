@@ -1 +1,146 @@
 package state_test
+
+import (
+	"testing"
+
+	"launchpad.net/kjvonly-bql/bql/state"
+	"launchpad.net/kjvonly-bql/lex"
+)
+
+type token struct {
+	Type  state.ElementType
+	Value interface{}
+}
+
+type position struct {
+	Type state.ElementType
+	Pos  int
+}
+
+// lexAll returns the tokens of input up to, but not including, EOF.
+func lexAll(input string) []token {
+	l := state.BQLLexer(input)
+	ts := []token{}
+	for {
+		t, _, v := l.Lex()
+		if t == state.BqlEOF {
+			return ts
+		}
+		if t == lex.Error {
+			v = v.(error).Error()
+		}
+		ts = append(ts, token{state.TokenTypes[t], v})
+	}
+}
+
+func checkTokens(t *testing.T, input string, expected []token) {
+	t.Helper()
+
+	ts := lexAll(input)
+	if len(ts) != len(expected) {
+		t.Fatalf("%q: expected %d tokens but got %d: %v", input, len(expected), len(ts), ts)
+	}
+
+	for i := range ts {
+		if ts[i] != expected[i] {
+			t.Fatalf("%q: expected token %v but got %v", input, expected[i], ts[i])
+		}
+	}
+}
+
+func TestLexKeywords(t *testing.T) {
+	checkTokens(t, "book AND text Or NOT chapter", []token{
+		{state.IDENTIFIER, "book"},
+		{state.AND_KEYWORD, "AND"},
+		{state.IDENTIFIER, "text"},
+		{state.OR_KEYWORD, "Or"},
+		{state.NOT_KEYWORD, "NOT"},
+		{state.IDENTIFIER, "chapter"},
+	})
+}
+
+func TestLexNot(t *testing.T) {
+	checkTokens(t, `!(text = "sin")`, []token{
+		{state.NOT, "!"},
+		{state.LPAR, '('},
+		{state.IDENTIFIER, "text"},
+		{state.EQ, "="},
+		{state.STRING_LITERAL, "sin"},
+		{state.RPAR, ')'},
+	})
+}
+
+func TestLexKeywordPrefixIsIdentifier(t *testing.T) {
+	checkTokens(t, "nothing android", []token{
+		{state.IDENTIFIER, "nothing"},
+		{state.IDENTIFIER, "android"},
+	})
+}
+
+func TestLexOperators(t *testing.T) {
+	checkTokens(t, "= != ~ !~ < > <= >= !", []token{
+		{state.EQ, "="},
+		{state.NEQ, "!="},
+		{state.CONTAINS, "~"},
+		{state.NOT_CONTAINS, "!~"},
+		{state.LT, "<"},
+		{state.GT, ">"},
+		{state.LTE, "<="},
+		{state.GTE, ">="},
+		{state.NOT, "!"},
+	})
+}
+
+func TestLexOperatorsWithoutSpaces(t *testing.T) {
+	checkTokens(t, `book!="john"`, []token{
+		{state.IDENTIFIER, "book"},
+		{state.NEQ, "!="},
+		{state.STRING_LITERAL, "john"},
+	})
+}
+
+func TestLexList(t *testing.T) {
+	checkTokens(t, `book not in ("john",mark)`, []token{
+		{state.IDENTIFIER, "book"},
+		{state.NOT_KEYWORD, "not"},
+		{state.IN_KEYWORD, "in"},
+		{state.LPAR, '('},
+		{state.STRING_LITERAL, "john"},
+		{state.COMMA, ','},
+		{state.IDENTIFIER, "mark"},
+		{state.RPAR, ')'},
+	})
+}
+
+func TestLexNear(t *testing.T) {
+	checkTokens(t, `text NEAR("faith", works)`, []token{
+		{state.IDENTIFIER, "text"},
+		{state.NEAR_KEYWORD, "NEAR"},
+		{state.LPAR, '('},
+		{state.STRING_LITERAL, "faith"},
+		{state.COMMA, ','},
+		{state.IDENTIFIER, "works"},
+		{state.RPAR, ')'},
+	})
+}
+
+func TestLexNumbers(t *testing.T) {
+	l := state.BQLLexer("chapter >= 3 and verse < 1.5")
+	expected := []position{
+		{state.IDENTIFIER, 0},
+		{state.GTE, 8},
+		{state.INT_LITERAL, 11},
+		{state.AND_KEYWORD, 13},
+		{state.IDENTIFIER, 17},
+		{state.LT, 23},
+		{state.FLOAT_LITERAL, 25},
+		{state.EOF, 28},
+	}
+
+	for _, e := range expected {
+		tok, p, v := l.Lex()
+		if state.TokenTypes[tok] != e.Type || p != e.Pos {
+			t.Fatalf("expected %s at %d but got %s at %d (%v)", e.Type, e.Pos, state.TokenTypes[tok], p, v)
+		}
+	}
+}
@@ -3,31 +3,91 @@ package state
 // TOKEN SETS
 
 const STRING_LITERAL ElementType = "STRING_LITERAL"
-const NUMBER_LITERAL ElementType = "NUMBER_LITERAL"
+const INT_LITERAL ElementType = "INT_LITERAL"
+const FLOAT_LITERAL ElementType = "FLOAT_LITERAL"
 
 const IDENTIFIER ElementType = "IDENTIFIER"
 
+const EOF ElementType = "EOF"
+const ERROR ElementType = "error"
+const SEMICOLON ElementType = "semicolon"
+
+const LPAR ElementType = "LPAR"
+const RPAR ElementType = "RPAR"
+const COMMA ElementType = "COMMA"
+
 // KEYWORDS
 
 const AND_KEYWORD ElementType = "AND_KEYWORD"
 const OR_KEYWORD ElementType = "OR_KEYWORD"
+const NOT_KEYWORD ElementType = "NOT_KEYWORD"
+const IN_KEYWORD ElementType = "IN_KEYWORD"
+const ORDER_KEYWORD ElementType = "ORDER_KEYWORD"
+const BY_KEYWORD ElementType = "BY_KEYWORD"
+const ASC_KEYWORD ElementType = "ASC_KEYWORD"
+const DESC_KEYWORD ElementType = "DESC_KEYWORD"
+const LIMIT_KEYWORD ElementType = "LIMIT_KEYWORD"
+const OFFSET_KEYWORD ElementType = "OFFSET_KEYWORD"
+const NEAR_KEYWORD ElementType = "NEAR_KEYWORD"
 
 // Operators
 const EQ ElementType = "EQ"
+const NEQ ElementType = "NEQ"
+const CONTAINS ElementType = "CONTAINS"
+const NOT_CONTAINS ElementType = "NOT_CONTAINS"
+const LT ElementType = "LT"
+const GT ElementType = "GT"
+const LTE ElementType = "LTE"
+const GTE ElementType = "GTE"
+const NOT ElementType = "NOT"
+const IN ElementType = "IN"
+const NOT_IN ElementType = "NOT_IN"
+const NEAR ElementType = "NEAR"
 
+// VALID_FIELD_NAMES are the tokens that can name a field. Whether the name is
+// a known field is checked after parsing against the parser's field registry.
 var VALID_FIELD_NAMES = map[ElementType]bool{
 	STRING_LITERAL: true,
 	IDENTIFIER:     true,
 }
 
+var FUNCTION_NAMES = map[ElementType]bool{
+	IDENTIFIER: true,
+}
+
 var SIMPLE_OPERATORS = map[ElementType]bool{
-	EQ: true,
+	EQ:           true,
+	NEQ:          true,
+	CONTAINS:     true,
+	NOT_CONTAINS: true,
+	LT:           true,
+	GT:           true,
+	LTE:          true,
+	GTE:          true,
+}
+
+var IN_OPERATORS = map[ElementType]bool{
+	IN_KEYWORD: true,
+}
+
+var NEAR_OPERATORS = map[ElementType]bool{
+	NEAR_KEYWORD: true,
+}
+
+var NOT_KEYWORDS = map[ElementType]bool{
+	NOT_KEYWORD: true,
 }
 
 var LITERALS = map[ElementType]bool{
 	STRING_LITERAL: true,
 	IDENTIFIER:     true,
-	NUMBER_LITERAL: true,
+	INT_LITERAL:    true,
+	FLOAT_LITERAL:  true,
+}
+
+var NUMBER_LITERALS = map[ElementType]bool{
+	INT_LITERAL:   true,
+	FLOAT_LITERAL: true,
 }
 
 var AND_OPERATORS = map[ElementType]bool{
@@ -37,3 +97,57 @@ var AND_OPERATORS = map[ElementType]bool{
 var OR_OPERATORS = map[ElementType]bool{
 	OR_KEYWORD: true,
 }
+
+var NOT_OPERATORS = map[ElementType]bool{
+	NOT_KEYWORD: true,
+	NOT:         true,
+}
+
+var LEFT_PARENTHESIS = map[ElementType]bool{
+	LPAR: true,
+}
+
+var RIGHT_PARENTHESIS = map[ElementType]bool{
+	RPAR: true,
+}
+
+var SEPARATORS = map[ElementType]bool{
+	COMMA: true,
+}
+
+var ORDER_KEYWORDS = map[ElementType]bool{
+	ORDER_KEYWORD: true,
+}
+
+var BY_KEYWORDS = map[ElementType]bool{
+	BY_KEYWORD: true,
+}
+
+var SORT_DIRECTIONS = map[ElementType]bool{
+	ASC_KEYWORD:  true,
+	DESC_KEYWORD: true,
+}
+
+var LIMIT_KEYWORDS = map[ElementType]bool{
+	LIMIT_KEYWORD: true,
+}
+
+var OFFSET_KEYWORDS = map[ElementType]bool{
+	OFFSET_KEYWORD: true,
+}
+
+// QUERY_CLAUSE_KEYWORDS start the clauses that may follow the or_clause of a
+// query.
+var QUERY_CLAUSE_KEYWORDS = map[ElementType]bool{
+	ORDER_KEYWORD:  true,
+	LIMIT_KEYWORD:  true,
+	OFFSET_KEYWORD: true,
+}
+
+var TERMINATORS = map[ElementType]bool{
+	SEMICOLON: true,
+}
+
+var END_OF_QUERY = map[ElementType]bool{
+	EOF: true,
+}
@@ -192,6 +192,21 @@ func (l *Lexer) File() *File {
 	return l.f
 }
 
+// Offset returns the file offset just past the last rune read from the input.
+// Provided that state functions back up any look-ahead before emitting a
+// token, calling Offset right after Lex returns the end offset of the returned
+// token.
+func (l *Lexer) Offset() int {
+	u := l.undo[l.ur]
+	switch {
+	case u.p < 0:
+		return 0
+	case u.r == EOF:
+		return u.p
+	}
+	return u.p + u.s
+}
+
 // Emit emits a single token of the given type and value. offset is the file
 // offset for the token (usually s.TokenPos()).
 //
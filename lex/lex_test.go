@@ -217,3 +217,37 @@ func TestEncodingError(t *testing.T) {
 		t.Fatal("unexpected As not working")
 	}
 }
+
+func TestLexer_Offset(t *testing.T) {
+	//                                          01234567
+	l := lex.NewLexer(lex.NewFile("test", strings.NewReader("ab  é cd")),
+		func(s *lex.State) lex.StateFn {
+			r := s.Next()
+			pos := s.Pos()
+			switch {
+			case r == lex.EOF:
+				s.Emit(pos, tokEOF, nil)
+			case r == ' ':
+			default:
+				for r = s.Next(); r != ' ' && r != lex.EOF; r = s.Next() {
+				}
+				s.Backup()
+				s.Emit(pos, tokChar, nil)
+			}
+			return nil
+		})
+	data := []struct {
+		p, end int
+	}{
+		{0, 2},
+		{4, 6},
+		{7, 9},
+		{9, 9},
+	}
+	for _, d := range data {
+		_, p, _ := l.Lex()
+		if p != d.p || l.Offset() != d.end {
+			t.Errorf("Got: %d-%d, expected: %d-%d", p, l.Offset(), d.p, d.end)
+		}
+	}
+}